@@ -3,19 +3,29 @@
 // found in the LICENSE file.
 
 // Convert hex to base64
-package main
+package challenge01
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"io"
+
+	"github.com/derat/cryptopals/challenges"
 )
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 1, Title: "Convert hex to base64", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	const orig = "49276d206b696c6c696e6720796f757220627261696e206c696b65206120706f69736f6e6f7573206d757368726f6f6d"
 	b, err := hex.DecodeString(orig)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	fmt.Println(base64.StdEncoding.EncodeToString(b))
+	enc := base64.StdEncoding.EncodeToString(b)
+	fmt.Fprintln(w, enc)
+	return challenges.Check("base64 string", enc, "SSdtIGtpbGxpbmcgeW91ciBicmFpbiBsaWtlIGEgcG9pc29ub3VzIG11c2hyb29t")
 }
@@ -3,19 +3,28 @@
 // found in the LICENSE file.
 
 // Fixed XOR: Write a function that takes two equal-length buffers and produces their XOR combination.
-package main
+package challenge02
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 2, Title: "Fixed XOR", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	const (
 		a = "1c0111001f010100061a024b53535009181c"
 		b = "686974207468652062756c6c277320657965"
 	)
-	fmt.Println(hex.EncodeToString(common.XOR(common.Unhex(a), common.Unhex(b))))
+	x := hex.EncodeToString(common.XOR(common.Unhex(a), common.Unhex(b)))
+	fmt.Fprintln(w, x)
+	return challenges.Check("XOR", x, "746865206b696420646f6e277420706c6179")
 }
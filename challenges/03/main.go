@@ -3,17 +3,26 @@
 // found in the LICENSE file.
 
 // Single-byte XOR cipher
-package main
+package challenge03
 
 import (
+	"context"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 3, Title: "Single-byte XOR cipher", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	const s = "1b37373331363f78151b7f2b783431333d78397828372d363c78373e783a393b3736"
 	enc := common.Unhex(s)
 	key := common.SingleByteXOR(enc)
-	fmt.Printf("%#x: %q\n", key, common.XOR(enc, []byte{key}))
+	dec := common.XOR(enc, []byte{key})
+	fmt.Fprintf(w, "%#x: %q\n", key, dec)
+	return challenges.Check("plaintext", string(dec), "Cooking MC's like a pound of bacon")
 }
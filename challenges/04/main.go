@@ -3,18 +3,33 @@
 // found in the LICENSE file.
 
 // Detect single-character XOR
-package main
+package challenge04
 
 import (
+	"context"
+	_ "embed"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+//go:embed 4.txt
+var data string
+
+func init() {
+	challenges.Register(challenges.Challenge{Num: 4, Title: "Detect single-character XOR", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
+	encs, err := common.DecodeHexLines(data)
+	if err != nil {
+		return err
+	}
 	var bestDec []byte
 	var bestScore *common.Score
-	for _, enc := range common.ReadHexLines("4.txt") {
+	for _, enc := range encs {
 		for i := 0; i < 256; i++ {
 			dec := common.XOR(enc, []byte{byte(i)})
 			if score := common.EnglishScore(dec); score.Better(bestScore) {
@@ -23,5 +38,6 @@ func main() {
 			}
 		}
 	}
-	fmt.Printf("%q\n", bestDec)
+	fmt.Fprintf(w, "%q\n", bestDec)
+	return challenges.Check("plaintext", string(bestDec), "Now that the party is jumping\n")
 }
@@ -3,16 +3,26 @@
 // found in the LICENSE file.
 
 // Implement repeating-key XOR
-package main
+package challenge05
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
-	const plain = "Burning 'em, if you ain't quick and nimble I go crazy when I hear a cymbal"
-	fmt.Println(hex.EncodeToString(common.XOR([]byte(plain), []byte("ICE"))))
+func init() {
+	challenges.Register(challenges.Challenge{Num: 5, Title: "Implement repeating-key XOR", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
+	const plain = "Burning 'em, if you ain't quick and nimble\nI go crazy when I hear a cymbal"
+	enc := hex.EncodeToString(common.XOR([]byte(plain), []byte("ICE")))
+	fmt.Fprintln(w, enc)
+	return challenges.Check("ciphertext", enc, "0b3637272a2b2e63622c2e69692a23693a2a3c6324202d623d63343c2a26226324272765272a282b2f20"+
+		"430a652e2c652a3124333a653e2b2027630c692b20283165286326302e27282f")
 }
@@ -3,70 +3,45 @@
 // found in the LICENSE file.
 
 // Break repeating-key XOR
-package main
+package challenge06
 
 import (
+	"context"
+	_ "embed"
+	"encoding/base64"
 	"fmt"
-	"sort"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
-	// Suggested test of Hamming distance code (should be 37)
-	//fmt.Println(common.Hamming([]byte("this is a test"), []byte("wokka wokka!!!")))
-
-	enc := common.ReadBase64("6.txt")
+//go:embed 6.txt
+var data string
 
-	const (
-		minKeysize  = 2
-		maxKeysize  = 40
-		keyBlocks   = 4
-		numKeysizes = 5
-	)
-	if keyBlocks*maxKeysize > len(enc) {
-		panic(fmt.Sprintf("can't check %v blocks of size %v in input of size %v", keyBlocks, maxKeysize, len(enc)))
-	}
+func init() {
+	challenges.Register(challenges.Challenge{Num: 6, Title: "Break repeating-key XOR", Run: Run})
+}
 
-	type keysizeDist struct {
-		size int
-		dist float64
+func Run(ctx context.Context, w io.Writer) error {
+	// Suggested test of Hamming distance code (should be 37)
+	if d := common.Hamming([]byte("this is a test"), []byte("wokka wokka!!!")); d != 37 {
+		return fmt.Errorf("Hamming distance is %v; want 37", d)
 	}
-	var keysizeDists []keysizeDist
 
-	for ks := minKeysize; ks <= maxKeysize; ks++ {
-		first := enc[0:ks]
-		distSum := 0.0
-		for i := 1; i < keyBlocks; i++ {
-			start := i * ks
-			second := enc[start : start+ks]
-			distSum += float64(common.Hamming(first, second)) / float64(ks)
-		}
-		dist := distSum / float64(keyBlocks-1)
-		keysizeDists = append(keysizeDists, keysizeDist{ks, dist})
+	enc, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
 	}
-	sort.Slice(keysizeDists, func(i, j int) bool {
-		return keysizeDists[i].dist < keysizeDists[j].dist
+	cands, err := common.BreakRepeatingXOR(enc, &common.XOROptions{
+		MinKeySize: 2,
+		MaxKeySize: 40,
+		Blocks:     4,
+		KeySizes:   5,
 	})
-
-	var bestKey, bestDec []byte
-	var bestScore *common.Score
-	for _, kd := range keysizeDists[:numKeysizes] {
-		key := make([]byte, kd.size)
-		for i := range key {
-			var block []byte
-			for j := i; j < len(enc); j += len(key) {
-				block = append(block, enc[j])
-			}
-			key[i] = common.SingleByteXOR(block)
-		}
-		dec := common.XOR(enc, key)
-		if score := common.EnglishScore(dec); score.Better(bestScore) {
-			bestKey = key
-			bestDec = dec
-			bestScore = &score
-		}
+	if err != nil {
+		return err
 	}
-
-	fmt.Printf("%q: %q", bestKey, bestDec)
+	fmt.Fprintf(w, "%q: %q\n", cands[0].Key, cands[0].Plain)
+	return challenges.Check("key", string(cands[0].Key), "Terminator X: Bring the noise")
 }
@@ -3,17 +3,37 @@
 // found in the LICENSE file.
 
 // AES in ECB mode
-package main
+package challenge07
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+//go:embed 7.txt
+var data string
+
+func init() {
+	challenges.Register(challenges.Challenge{Num: 7, Title: "AES in ECB mode", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	const key = "YELLOW SUBMARINE" // given in exercise
-	enc := common.ReadBase64("7.txt")
+	enc, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
 	dec := common.DecryptAES(enc, []byte(key), nil /* nil IV for ECB */)
-	fmt.Printf("%q\n", dec)
+	fmt.Fprintf(w, "%q\n", dec)
+	if !bytes.HasPrefix(dec, []byte(challenges.FunkyMusic)) {
+		return fmt.Errorf("decrypted to unexpected text %q", dec)
+	}
+	return nil
 }
@@ -3,28 +3,37 @@
 // found in the LICENSE file.
 
 // Detect AES in ECB mode
-package main
+package challenge08
 
 import (
-	"encoding/hex"
+	"context"
+	_ "embed"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+//go:embed 8.txt
+var data string
+
+func init() {
+	challenges.Register(challenges.Challenge{Num: 8, Title: "Detect AES in ECB mode", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	const bs = 16
-	for i, enc := range common.ReadHexLines("8.txt") {
-		seen := make(map[string]struct{})
-		for start := 0; start < len(enc); start += bs {
-			block := hex.EncodeToString(enc[start : start+bs])
-			if _, ok := seen[block]; ok {
-				fmt.Printf("Block %q seen twice on line %d\n", block, i)
-				os.Exit(0)
-			}
-			seen[block] = struct{}{}
+	cts, err := common.DecodeHexLines(data)
+	if err != nil {
+		return err
+	}
+	for i, g := range common.ClassifyCiphertexts(cts, bs) {
+		if g.Mode == common.ModeECB {
+			fmt.Fprintf(w, "Line %d appears to use ECB (confidence %.2f)\n", i, g.Confidence)
+			return challenges.Check("line", i, 132)
 		}
 	}
-	fmt.Println("Duplicate block not found")
+	return errors.New("duplicate block not found")
 }
@@ -3,15 +3,24 @@
 // found in the LICENSE file.
 
 // Implement PKCS#7 padding
-package main
+package challenge09
 
 import (
+	"context"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 9, Title: "Implement PKCS#7 padding", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	const in = "YELLOW SUBMARINE"
-	fmt.Printf("%q\n", common.PadPKCS7([]byte(in), 20))
+	padded := common.PadPKCS7([]byte(in), 20)
+	fmt.Fprintf(w, "%q\n", padded)
+	return challenges.Check("padded text", string(padded), "YELLOW SUBMARINE\x04\x04\x04\x04")
 }
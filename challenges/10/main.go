@@ -3,20 +3,39 @@
 // found in the LICENSE file.
 
 // Implement CBC mode
-package main
+package challenge10
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+//go:embed 10.txt
+var data string
+
+func init() {
+	challenges.Register(challenges.Challenge{Num: 10, Title: "Implement CBC mode", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	const key = "YELLOW SUBMARINE"
-	enc := common.ReadBase64("10.txt")
+	enc, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
 	iv := make([]byte, 16)
 	dec := common.DecryptAES(enc, []byte(key), iv)
-	fmt.Printf("%q\n", dec)
+	fmt.Fprintf(w, "%q\n", dec)
+	if !bytes.HasPrefix(dec, []byte(challenges.FunkyMusic)) {
+		return fmt.Errorf("decrypted to unexpected text %q", dec)
+	}
 
 	const txt = "Here's some example text. I'm just going to keep writing until I get bored."
 	iv[0] = 0x23
@@ -24,5 +43,6 @@ func main() {
 	b := common.PadPKCS7([]byte(txt), 16)
 	enc = common.EncryptAES(b, []byte(key), iv)
 	dec = common.DecryptAES(enc, []byte(key), iv)
-	fmt.Printf("%q\n", dec)
+	fmt.Fprintf(w, "%q\n", dec)
+	return challenges.Check("round-tripped text", dec, b)
 }
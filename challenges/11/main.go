@@ -3,18 +3,26 @@
 // found in the LICENSE file.
 
 // An ECB/CBC detection oracle
-package main
+package challenge11
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
+func init() {
+	challenges.Register(challenges.Challenge{Num: 11, Title: "An ECB/CBC detection oracle", Run: Run})
+}
+
 // encrypt encrypts b using AES-128 with a random key and 5-10 random bytes before and after b.
 // It uses ECB half the time and CBC with a random IV the other half.
-func encrypt(b []byte) []byte {
+// The mode that was used is also returned so that the guess can be checked.
+func encrypt(b []byte) ([]byte, common.Mode) {
 	pre := common.RandBytes(5 + common.RandInt(6))
 	suf := common.RandBytes(5 + common.RandInt(6))
 	plain := make([]byte, 0, len(pre)+len(b)+len(suf))
@@ -24,26 +32,34 @@ func encrypt(b []byte) []byte {
 	plain = common.PadPKCS7(plain, 16)
 
 	var iv []byte
+	mode := common.ModeECB
 	if common.RandInt(2) == 1 {
 		iv = common.RandBytes(16) // use CBC
+		mode = common.ModeCBC
 	}
 	key := common.RandBytes(16)
-	return common.EncryptAES(plain, key, iv)
+	return common.EncryptAES(plain, key, iv), mode
 }
 
-func main() {
+func Run(ctx context.Context, w io.Writer) error {
 	plain := bytes.Repeat([]byte{'A'}, 3*16)
 	var ecb, cbc int
 	for i := 0; i < 100; i++ {
-		enc := encrypt(plain)
-		// Compare the second and third blocks, which should consist entirely of our plaintext.
+		enc, mode := encrypt(plain)
+		// The second and third blocks consist entirely of our plaintext.
 		// If ECB is used, they'll be the same.
-		if bytes.Equal(enc[16:32], enc[32:48]) {
+		guess := common.ModeCBC
+		if common.DetectModeCiphertext(enc, 16).Mode == common.ModeECB {
+			guess = common.ModeECB
 			ecb++
 		} else {
 			cbc++
 		}
+		if guess != mode {
+			return fmt.Errorf("guessed %v for iteration %d; actual mode was %v", guess, i, mode)
+		}
 	}
-	fmt.Println("ECB:", ecb)
-	fmt.Println("CBC:", cbc)
+	fmt.Fprintln(w, "ECB:", ecb)
+	fmt.Fprintln(w, "CBC:", cbc)
+	return nil
 }
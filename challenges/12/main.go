@@ -3,12 +3,15 @@
 // found in the LICENSE file.
 
 // Byte-at-a-time ECB decryption (Simple)
-package main
+package challenge12
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
@@ -17,35 +20,45 @@ aGFpciBjYW4gYmxvdwpUaGUgZ2lybGllcyBvbiBzdGFuZGJ5IHdhdmluZyBq
 dXN0IHRvIHNheSBoaQpEaWQgeW91IHN0b3A/IE5vLCBJIGp1c3QgZHJvdmUg
 YnkK`
 
-var secretDec []byte // decoded version of secret
-var key []byte       // fixed key
-
 func init() {
-	var err error
-	if secretDec, err = base64.StdEncoding.DecodeString(secret); err != nil {
-		panic(err)
-	}
-	key = common.RandBytes(16)
+	challenges.Register(challenges.Challenge{Num: 12, Title: "Byte-at-a-time ECB decryption (Simple)", Run: Run})
 }
 
-// encrypt appends secretDec to b and encrypts using AES-128 in ECB mode with key.
-func encrypt(b []byte) []byte {
-	plain := make([]byte, 0, len(b)+len(secretDec))
-	plain = append(plain, b...)
-	plain = append(plain, secretDec...)
-	plain = common.PadPKCS7(plain, 16)
-	return common.EncryptAES(plain, key, nil)
-}
+func Run(ctx context.Context, w io.Writer) error {
+	secretDec, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return err
+	}
+	key := common.RandBytes(16) // fixed key
 
-func main() {
-	bs := common.BlockSizeECB(encrypt)
-	fmt.Println("Using ECB with block size", bs)
-	secretLen := common.SuffixLen(encrypt, bs)
-	fmt.Println("Secret text has length", secretLen)
+	// encrypt appends secretDec to b and encrypts using AES-128 in ECB mode with key.
+	encrypt := func(b []byte) []byte {
+		plain := make([]byte, 0, len(b)+len(secretDec))
+		plain = append(plain, b...)
+		plain = append(plain, secretDec...)
+		plain = common.PadPKCS7(plain, 16)
+		return common.EncryptAES(plain, key, nil)
+	}
+
+	bs, err := common.TryBlockSizeECB(encrypt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Using ECB with block size", bs)
+	secretLen, err := common.TrySuffixLen(encrypt, bs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Secret text has length", secretLen)
 
 	var known []byte
 	for len(known) < secretLen {
-		known = append(known, common.NextSuffixByteECB(encrypt, bs, known))
+		b, err := common.TryNextSuffixByteECB(encrypt, bs, known)
+		if err != nil {
+			return err
+		}
+		known = append(known, b)
 	}
-	fmt.Printf("%q\n", known)
+	fmt.Fprintf(w, "%q\n", known)
+	return challenges.Check("secret", known, secretDec)
 }
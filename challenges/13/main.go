@@ -3,17 +3,22 @@
 // found in the LICENSE file.
 
 // ECB cut-and-paste
-package main
+package challenge13
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-var key []byte = common.RandBytes(16) // fixed key
+func init() {
+	challenges.Register(challenges.Challenge{Num: 13, Title: "ECB cut-and-paste", Run: Run})
+}
 
 // parseKeyVals parses a string like "foo=bar&baz=qux&zap=zazzle".
 func parseKeyVals(s string) (map[string]string, error) {
@@ -35,12 +40,12 @@ func profileFor(email string) string {
 	return fmt.Sprintf("email=%v&uid=10&role=user", email)
 }
 
-func encrypt(email string) []byte {
+func encrypt(email string, key []byte) []byte {
 	plain := common.PadPKCS7([]byte(profileFor(email)), 16)
 	return common.EncryptAES(plain, key, nil)
 }
 
-func decrypt(enc []byte) (map[string]string, error) {
+func decrypt(enc, key []byte) (map[string]string, error) {
 	padded := common.DecryptAES(enc, key, nil)
 	plain, err := common.UnpadPKCS7(padded)
 	if err != nil {
@@ -49,31 +54,42 @@ func decrypt(enc []byte) (map[string]string, error) {
 	return parseKeyVals(string(plain))
 }
 
-func main() {
-	f := func(b []byte) []byte { return encrypt(string(b)) }
-	bs := common.BlockSizeECB(f)
-	pl := common.PrefixLen(f, bs)
-	sl := common.SuffixLen(f, bs)
-	fmt.Printf("Prefix length is %v, suffix is %v\n", pl, sl)
+func Run(ctx context.Context, w io.Writer) error {
+	key := common.RandBytes(16) // fixed key
+	f := func(b []byte) []byte { return encrypt(string(b), key) }
+	bs, err := common.TryBlockSizeECB(f)
+	if err != nil {
+		return err
+	}
+	pl, err := common.TryPrefixLen(f, bs)
+	if err != nil {
+		return err
+	}
+	sl, err := common.TrySuffixLen(f, bs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Prefix length is %v, suffix is %v\n", pl, sl)
 
 	// Create a block containing "admin" followed by PKCS#7 padding.
 	addrLen := bs - pl
 	buf := append(bytes.Repeat([]byte{'A'}, addrLen), common.PadPKCS7([]byte("admin"), bs)...)
-	enc := encrypt(string(buf))
+	enc := encrypt(string(buf), key)
 	adminBlock := enc[bs:]
 
 	// Figure out how long the address needs to be to push the role value to the beginning of a block.
 	// In other words, we want the last four bytes of the suffix ("user") to be at the start of a block.
 	addrLen = bs - ((pl + sl - 4) % bs)
-	fmt.Printf("Address length is %v\n", addrLen)
+	fmt.Fprintf(w, "Address length is %v\n", addrLen)
 
 	// Generate an encrypted buffer and overwrite "user" with "admin".
-	enc = encrypt(strings.Repeat("A", addrLen))
+	enc = encrypt(strings.Repeat("A", addrLen), key)
 	copy(enc[len(enc)-bs:], adminBlock)
 
-	if m, err := decrypt(enc); err != nil {
-		fmt.Printf("Decryption failed: %v\n", err)
-	} else {
-		fmt.Println(m)
+	m, err := decrypt(enc, key)
+	if err != nil {
+		return fmt.Errorf("decryption failed: %v", err)
 	}
+	fmt.Fprintln(w, m)
+	return challenges.Check("role", m["role"], "admin")
 }
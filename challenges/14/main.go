@@ -3,12 +3,15 @@
 // found in the LICENSE file.
 
 // Byte-at-a-time ECB decryption (Harder)
-package main
+package challenge14
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
@@ -17,45 +20,52 @@ aGFpciBjYW4gYmxvdwpUaGUgZ2lybGllcyBvbiBzdGFuZGJ5IHdhdmluZyBq
 dXN0IHRvIHNheSBoaQpEaWQgeW91IHN0b3A/IE5vLCBJIGp1c3QgZHJvdmUg
 YnkK`
 
-var secretDec []byte // decoded version of secret
-var key []byte = common.RandBytes(16)
-var randomPrefix []byte = common.RandBytes(1 + common.RandInt(60))
-
 func init() {
-	var err error
-	if secretDec, err = base64.StdEncoding.DecodeString(secret); err != nil {
-		panic(err)
-	}
+	challenges.Register(challenges.Challenge{Num: 14, Title: "Byte-at-a-time ECB decryption (Harder)", Run: Run})
 }
 
-// encrypt prepends a fixed random prefix and appends secretDec to b and encrypts using AES-128 in ECB mode.
-func encrypt(b []byte) []byte {
-	plain := make([]byte, 0, len(randomPrefix)+len(b)+len(secretDec))
-	plain = append(plain, randomPrefix...)
-	plain = append(plain, b...)
-	plain = append(plain, secretDec...)
-	plain = common.PadPKCS7(plain, 16)
-	return common.EncryptAES(plain, key, nil)
-}
+func Run(ctx context.Context, w io.Writer) error {
+	secretDec, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return err
+	}
+	key := common.RandBytes(16)
+	randomPrefix := common.RandBytes(1 + common.RandInt(60))
 
-func main() {
-	bs := common.BlockSizeECB(encrypt)
-	fmt.Println("Using ECB with block size", bs)
-	pl := common.PrefixLen(encrypt, bs)
-	sl := common.SuffixLen(encrypt, bs)
-	fmt.Printf("Prefix length is %v, suffix is %v\n", pl, sl)
-	pad := bs - (pl % bs)
-	fmt.Printf("Need %v byte(s) to start new block\n", pad)
-
-	// Wrap the encryption function to eliminate its fixed prefix.
-	f := func(b []byte) []byte {
-		enc := encrypt(append(common.A(pad), b...))
-		return enc[pl+pad:]
+	// encrypt prepends a fixed random prefix and appends secretDec to b and encrypts using AES-128 in ECB mode.
+	encrypt := func(b []byte) []byte {
+		plain := make([]byte, 0, len(randomPrefix)+len(b)+len(secretDec))
+		plain = append(plain, randomPrefix...)
+		plain = append(plain, b...)
+		plain = append(plain, secretDec...)
+		plain = common.PadPKCS7(plain, 16)
+		return common.EncryptAES(plain, key, nil)
+	}
+
+	bs, err := common.TryBlockSizeECB(encrypt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Using ECB with block size", bs)
+	pl, err := common.TryPrefixLen(encrypt, bs)
+	if err != nil {
+		return err
+	}
+	sl, err := common.TrySuffixLen(encrypt, bs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Prefix length is %v, suffix is %v\n", pl, sl)
+	if err := challenges.Check("prefix length", pl, len(randomPrefix)); err != nil {
+		return err
 	}
 
-	var known []byte
-	for len(known) < sl {
-		known = append(known, common.NextSuffixByteECB(f, bs, known))
+	// TryDecryptSuffixECB pads out the fixed prefix to start a new block and then
+	// decrypts the suffix one byte at a time.
+	suf, err := common.TryDecryptSuffixECB(encrypt)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("%q\n", known)
+	fmt.Fprintf(w, "%q\n", suf)
+	return challenges.Check("secret", suf, secretDec)
 }
@@ -3,26 +3,41 @@
 // found in the LICENSE file.
 
 // PKCS#7 padding validation
-package main
+package challenge15
 
 import (
+	"context"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
-	for _, s := range []string{
-		"ICE ICE BABY\x04\x04\x04\x04", // valid
-		"ICE ICE BABY\x05\x05\x05\x05", // invalid
-		"ICE ICE BABY\x01\x02\x03\x04", // invalid
-		"",                             // invalid (not in challenge)
-		"\x12",                         // invalid (not in challenge)
+func init() {
+	challenges.Register(challenges.Challenge{Num: 15, Title: "PKCS#7 padding validation", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
+	for _, tc := range []struct {
+		s     string
+		valid bool
+	}{
+		{"ICE ICE BABY\x04\x04\x04\x04", true},
+		{"ICE ICE BABY\x05\x05\x05\x05", false},
+		{"ICE ICE BABY\x01\x02\x03\x04", false},
+		{"", false},     // not in challenge
+		{"\x12", false}, // not in challenge
 	} {
-		if u, err := common.UnpadPKCS7([]byte(s)); err != nil {
-			fmt.Printf("UnpadPKCS7(%q) failed: %v\n", s, err)
+		u, err := common.UnpadPKCS7([]byte(tc.s))
+		if err != nil {
+			fmt.Fprintf(w, "UnpadPKCS7(%q) failed: %v\n", tc.s, err)
 		} else {
-			fmt.Printf("UnpadPKCS7(%q) = %q\n", s, u)
+			fmt.Fprintf(w, "UnpadPKCS7(%q) = %q\n", tc.s, u)
+		}
+		if valid := err == nil; valid != tc.valid {
+			return fmt.Errorf("UnpadPKCS7(%q) validity is %v; want %v", tc.s, valid, tc.valid)
 		}
 	}
+	return nil
 }
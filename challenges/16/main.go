@@ -3,13 +3,17 @@
 // found in the LICENSE file.
 
 // CBC bitflipping attacks
-package main
+package challenge16
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
@@ -18,25 +22,29 @@ const (
 	suffix = ";comment2=%20like%20a%20pound%20of%20bacon"
 )
 
-var key []byte = common.RandBytes(16)
-var iv []byte = common.RandBytes(16)
-
-// encrypt adds prefix and suffix to s and encrypts using AES-128 in CBC mode.
-func encrypt(s string) []byte {
-	s = strings.ReplaceAll(s, ";", "%3B")
-	s = strings.ReplaceAll(s, "=", "%3D")
-	plain := prefix + s + suffix
-	padded := common.PadPKCS7([]byte(plain), 16)
-	return common.EncryptAES(padded, key, iv)
+func init() {
+	challenges.Register(challenges.Challenge{Num: 16, Title: "CBC bitflipping attacks", Run: Run})
 }
 
-// admin decrypts b and returns true if the resulting string contains ";admin=true;".
-func admin(b []byte) bool {
-	padded := common.DecryptAES(b, key, iv)
-	return bytes.Contains(padded, []byte(";admin=true;"))
-}
+func Run(ctx context.Context, w io.Writer) error {
+	key := common.RandBytes(16)
+	iv := common.RandBytes(16)
+
+	// encrypt adds prefix and suffix to s and encrypts using AES-128 in CBC mode.
+	encrypt := func(s string) []byte {
+		s = strings.ReplaceAll(s, ";", "%3B")
+		s = strings.ReplaceAll(s, "=", "%3D")
+		plain := prefix + s + suffix
+		padded := common.PadPKCS7([]byte(plain), 16)
+		return common.EncryptAES(padded, key, iv)
+	}
+
+	// admin decrypts b and returns true if the resulting string contains ";admin=true;".
+	admin := func(b []byte) bool {
+		padded := common.DecryptAES(b, key, iv)
+		return bytes.Contains(padded, []byte(";admin=true;"))
+	}
 
-func main() {
 	// From the challenge:
 	//
 	//   You're relying on the fact that in CBC mode, a 1-bit error in a ciphertext block:
@@ -50,8 +58,11 @@ func main() {
 
 	const bs = 16 // TODO: Detect this by just adding bytes until we see it grow?
 	f := func(b []byte) []byte { return encrypt(string(b)) }
-	pl := common.PrefixLen(f, bs)
-	fmt.Printf("Prefix length is %v\n", pl)
+	pl, err := common.TryPrefixLen(f, bs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Prefix length is %v\n", pl)
 
 	pad := common.A(pl % bs)                          // pad out the first modifiable block
 	b := append(pad, common.A(bs)...)                 // add a full block for flipping bits
@@ -62,9 +73,9 @@ func main() {
 	enc[bo] ^= ';'
 	enc[bo+6] ^= '='
 	enc[bo+11] ^= ';'
-	if a := admin(enc); a {
-		fmt.Println("Got admin!")
-	} else {
-		fmt.Println("Didn't get admin. :-(")
+	if !admin(enc) {
+		return errors.New("didn't get admin")
 	}
+	fmt.Fprintln(w, "Got admin!")
+	return nil
 }
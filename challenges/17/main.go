@@ -3,12 +3,15 @@
 // found in the LICENSE file.
 
 // The CBC padding oracle
-package main
+package challenge17
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
@@ -25,106 +28,38 @@ var input = []string{ // provided by challenge
 	"MDAwMDA5aXRoIG15IHJhZy10b3AgZG93biBzbyBteSBoYWlyIGNhbiBibG93",
 }
 
-var key []byte = common.RandBytes(16)
-var initVec []byte = common.RandBytes(16)
-
-func encrypt() (enc, iv []byte) {
-	bs := input[common.RandInt(len(input))]
-	s, err := base64.StdEncoding.DecodeString(bs)
-	if err != nil {
-		panic(fmt.Sprintf("Failed decoding %q: %v", bs, err))
-	}
-	padded := common.PadPKCS7([]byte(s), 16)
-	return common.EncryptAES(padded, key, initVec), initVec
-}
-
-func checkPadding(enc []byte) (valid bool) {
-	padded := common.DecryptAES(enc, key, initVec)
-	_, err := common.UnpadPKCS7(padded)
-	return err == nil
+func init() {
+	challenges.Register(challenges.Challenge{Num: 17, Title: "The CBC padding oracle", Run: Run})
 }
 
-func decryptByte(enc, iv []byte, bs int, known []byte) byte {
-	// From the challenge:
-	//
-	//   The fundamental insight behind this attack is that the byte 01h is valid padding, and occur
-	//   in 1/256 trials of "randomized" plaintexts produced by decrypting a tampered ciphertext.
-	//   - 02h in isolation is not valid padding.
-	//   - 02h 02h is valid padding, but is much less likely to occur randomly than 01h.
-	//   - 03h 03h 03h is even less likely.
-	//   So you can assume that if you corrupt a decryption AND it had valid padding, you know what that padding byte is.
-	//
-	// The general approach for decrypting block N is:
-	//
-	// - Manipulate the last byte of block N-1 to force the last byte of N to 0x1, representing one byte of padding.
-	// - Now use that knowledge to set the last byte of N to 0x2, representing two bytes of padding.
-	// - Manipulate the second-to-last byte of of block N-1 until the padding is valid (i.e. that byte is also 0x2).
-	// - Now we know the second-to-last byte of block N.
-	// - Repeat until we know all of the bytes of block N.
-
-	// When we're decrypting block 0, there's no previous block to twiddle.
-	// The encryption function XORed block 0 with the IV, so we prepend the IV to the ciphertext so we can twiddle it.
-	// During decryption, block 1 (formerly block 0) will be XORed with block 0 (now the IV).
-	// In other words, the block still gets XORed against the same bytes (i.e. the IV), but now we can twiddle them!
-	// I found this pretty subtle.
-	enc = append(iv, enc...)
-
-	// Drop all of the already-decrypted blocks so the target block will be interpreted as containing padding.
-	for len(known) >= bs {
-		known = known[:len(known)-bs]
-		enc = enc[:len(enc)-bs]
-	}
-
-	target := len(enc) - 1 - len(known) // index of byte that we're targeting
+func Run(ctx context.Context, w io.Writer) error {
+	const bs = 16
+	key := common.RandBytes(bs)
+	iv := common.RandBytes(bs)
 
-	// Manipulate the second-to-last block of the ciphertext so that the target
-	// byte and the bytes after it in the final block are all interpreted as padding.
-	pad := len(enc) - target
-	mod := append([]byte{}, enc...)
-	for i, orig := range known {
-		mod[target-bs+i+1] ^= (orig ^ byte(pad))
+	str := input[common.RandInt(len(input))]
+	secret, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return fmt.Errorf("failed decoding %q: %v", str, err)
 	}
+	enc := common.EncryptAES(common.PadPKCS7(secret, bs), key, iv)
 
-	// Modify the target byte until we get valid padding.
-	off := target - bs
-	for i := 0; i < 256; i++ {
-		mod[off] = enc[off] ^ byte(i)
-		if checkPadding(mod) {
-			// Special case: When we're targeting the final byte in the block, |pad| will be set to 1,
-			// but the byte may have multiple values that will result in valid padding:
-			// - 0x1 (always)
-			// - 0x2 (if preceded by 0x2)
-			// - 0x3 (if preceded by [0x3,0x3])
-			// - etc.
-			// In the 0x1 case, we'll still have valid padding after modifying the preceding byte.
-			// In all other cases, doing this will break the padding.
-			if len(known) == 0 {
-				mod[off-1] ^= mod[off-1]
-				if !checkPadding(mod) {
-					mod[off-1] = enc[off-1] // undo the change
-					continue
-				}
-			}
-
-			return byte(i ^ pad)
-		}
+	// checkPadding decrypts enc using iv and reports whether the plaintext has valid padding.
+	checkPadding := func(iv, enc []byte) (valid bool) {
+		padded := common.DecryptAES(enc, key, iv)
+		_, err := common.UnpadPKCS7(padded)
+		return err == nil
 	}
-	panic(fmt.Sprintf("failed to decode byte"))
-}
-
-func main() {
-	const bs = 16
-	enc, iv := encrypt()
 
-	var known []byte
-	for len(known) < len(enc) {
-		b := decryptByte(enc, iv, bs, known)
-		known = append([]byte{b}, known...)
+	known, queries, err := common.PaddingOracleAttack(checkPadding, iv, enc, bs)
+	if err != nil {
+		return fmt.Errorf("attack failed: %v", err)
 	}
+	fmt.Fprintf(w, "Decrypted %v byte(s) using %v queries\n", len(known), queries)
 	plain, err := common.UnpadPKCS7(known)
 	if err != nil {
-		fmt.Printf("Failed unpadding %q: %v\n", known, err)
-	} else {
-		fmt.Printf("%q\n", plain)
+		return fmt.Errorf("failed unpadding %q: %v", known, err)
 	}
+	fmt.Fprintf(w, "%q\n", plain)
+	return challenges.Check("plaintext", plain, secret)
 }
@@ -3,13 +3,16 @@
 // found in the LICENSE file.
 
 // Implement CTR, the stream cipher mode
-package main
+package challenge18
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
@@ -19,16 +22,21 @@ const (
 	nonce  = 0
 )
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 18, Title: "Implement CTR, the stream cipher mode", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
 	enc, err := base64.StdEncoding.DecodeString(secret)
 	if err != nil {
-		panic(fmt.Sprintf("failed decoding secret: %v", err))
+		return fmt.Errorf("failed decoding secret: %v", err)
 	}
 
 	var plain bytes.Buffer
 	ctr := common.NewCTR([]byte(key), nonce)
 	if err := ctr.Process(bytes.NewReader(enc), &plain); err != nil {
-		panic(fmt.Sprintf("failed processing data: %v", err))
+		return fmt.Errorf("failed processing data: %v", err)
 	}
-	fmt.Printf("%q\n", plain.String())
+	fmt.Fprintf(w, "%q\n", plain.String())
+	return challenges.Check("plaintext", plain.String(), "Yo, VIP Let's kick it Ice, Ice, baby Ice, Ice, baby ")
 }
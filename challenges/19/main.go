@@ -3,13 +3,16 @@
 // found in the LICENSE file.
 
 // Break fixed-nonce CTR mode using substitutions
-package main
+package challenge19
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
@@ -56,87 +59,64 @@ var data = []string{ // provided by challenge
 	"QSB0ZXJyaWJsZSBiZWF1dHkgaXMgYm9ybi4=",
 }
 
-var key = common.RandBytes(16)
-
 const nonce = 0
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 19, Title: "Break fixed-nonce CTR mode using substitutions", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
+	key := common.RandBytes(16)
 	ctr := common.NewCTR(key, nonce)
+	secs := make([][]byte, len(data))
 	encs := make([][]byte, len(data))
 	for i, d := range data {
 		sec, err := base64.StdEncoding.DecodeString(d)
 		if err != nil {
-			panic(fmt.Sprintf("failed decoding secret %q: %v", d, err))
+			return fmt.Errorf("failed decoding secret %q: %v", d, err)
 		}
 		var enc bytes.Buffer
 		ctr.Reset()
 		if err := ctr.Process(bytes.NewReader(sec), &enc); err != nil {
-			panic(fmt.Sprintf("failed encrypting string %d: %v", i, err))
+			return fmt.Errorf("failed encrypting string %d: %v", i, err)
 		}
+		secs[i] = sec
 		encs[i] = enc.Bytes()
 	}
 
-	maxLen := 0
-	for _, enc := range encs {
-		if len(enc) > maxLen {
-			maxLen = len(enc)
-		}
-	}
-
-	decs := make([][]byte, len(encs))
-
 	// Whoops, I think I accidentally used the approach from Challenge 20 here.
 	// Reading online afterwords, it sounds like many people just skipped this challenge
 	// and went to number 20. What's the point of doing this manually just to see how much it sucks?
-	for i := 0; i < maxLen; i++ {
-		buf := make([]byte, 0, len(encs)) // bytes at position i across all ciphertexts
-		for _, enc := range encs {
-			if i < len(enc) {
-				buf = append(buf, enc[i])
-			}
-		}
-		xor := common.SingleByteXOR(buf)
-
-		// When the cross-section consists only of letters, common.EnglishScore can't tell
-		// whether they should be lowercase or upper case. It also has trouble with the later
-		// bytes, where there's less data due to some of the lines being short. Hardcode some
-		// trickier bytes that I determined manually by looking at the automated results.
-		if i == 0 {
-			xor = encs[0][i] ^ 'I'
-		} else if i == 7 {
-			xor = encs[0][i] ^ 'm'
-		} else if i == 25 {
-			xor = encs[5][i] ^ 'd'
-		} else if i == 28 {
-			xor = encs[33][i] ^ 't'
-		} else if i == 29 {
-			xor = encs[35][i] ^ 't'
-		} else if i == 30 {
-			xor = encs[6][i] ^ 'i'
-		} else if i == 31 {
-			xor = encs[6][i] ^ 'd'
-		} else if i == 32 {
-			xor = encs[27][i] ^ 'd'
-		} else if i == 33 {
-			xor = encs[4][i] ^ 'e'
-		} else if i == 34 {
-			xor = encs[4][i] ^ 'a'
-		} else if i == 35 {
-			xor = encs[4][i] ^ 'd'
-		} else if i == 36 {
-			xor = encs[37][i] ^ 'n'
-		} else if i == 37 {
-			xor = encs[37][i] ^ ','
-		}
+	//
+	// common.EnglishScore can't tell whether letters should be uppercase or lowercase, and it
+	// has trouble with the later bytes, where there's less data due to some of the lines being
+	// short. BreakFixedNonceCTR fixes most of these by refining each column using bigram
+	// statistics learned from the other columns, although the final bytes of the longest line
+	// may still be wrong.
+	_, decs := common.BreakFixedNonceCTR(encs)
+	for _, dec := range decs {
+		fmt.Fprintf(w, "%q\n", dec)
+	}
 
-		for j, enc := range encs {
-			if i < len(enc) {
-				decs[j] = append(decs[j], enc[i]^xor)
+	// Only check columns that contain bytes from at least a few lines.
+	const minRows = 3
+	for i, dec := range decs {
+		for j := range dec {
+			if n := rowsWithLen(secs, j+1); n >= minRows && dec[j] != secs[i][j] {
+				return fmt.Errorf("line %d decrypted to %q; want %q", i, dec, secs[i])
 			}
 		}
 	}
+	return nil
+}
 
-	for _, dec := range decs {
-		fmt.Printf("%q\n", dec)
+// rowsWithLen returns the number of rows with at least n bytes.
+func rowsWithLen(rows [][]byte, n int) int {
+	var cnt int
+	for _, r := range rows {
+		if len(r) >= n {
+			cnt++
+		}
 	}
+	return cnt
 }
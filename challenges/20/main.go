@@ -3,37 +3,53 @@
 // found in the LICENSE file.
 
 // Break fixed-nonce CTR statistically
-package main
+package challenge20
 
 import (
 	"bytes"
+	"context"
+	_ "embed"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-var lines = common.ReadBase64Lines("20.txt")
-var key = common.RandBytes(16)
+//go:embed 20.txt
+var data string
 
 const nonce = 0
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 20, Title: "Break fixed-nonce CTR statistically", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
+	lines, err := common.DecodeBase64Lines(data)
+	if err != nil {
+		return err
+	}
+	key := common.RandBytes(16)
 	ctr := common.NewCTR(key, nonce)
 	encs := make([][]byte, len(lines))
 	for i, ln := range lines {
 		var enc bytes.Buffer
 		ctr.Reset()
 		if err := ctr.Process(bytes.NewReader(ln), &enc); err != nil {
-			panic(fmt.Sprintf("failed encrypting line %d: %v", i, err))
+			return fmt.Errorf("failed encrypting line %d: %v", i, err)
 		}
 		encs[i] = enc.Bytes()
 	}
 
-	maxLen := 0
+	maxLen, minLen := 0, len(encs[0])
 	for _, enc := range encs {
 		if len(enc) > maxLen {
 			maxLen = len(enc)
 		}
+		if len(enc) < minLen {
+			minLen = len(enc)
+		}
 	}
 
 	decs := make([][]byte, len(encs))
@@ -60,6 +76,12 @@ func main() {
 		}
 	}
 	for _, dec := range decs {
-		fmt.Printf("%q\n", dec)
+		fmt.Fprintf(w, "%q\n", dec)
+	}
+	for i, dec := range decs {
+		if err := challenges.Check(fmt.Sprintf("line %d prefix", i), dec[:minLen], lines[i][:minLen]); err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -3,17 +3,31 @@
 // found in the LICENSE file.
 
 // Implement the MT19937 Mersenne Twister RNG
-package main
+package challenge21
 
 import (
+	"context"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-func main() {
+func init() {
+	challenges.Register(challenges.Challenge{Num: 21, Title: "Implement the MT19937 Mersenne Twister RNG", Run: Run})
+}
+
+func Run(ctx context.Context, w io.Writer) error {
+	// First outputs of the reference implementation's genrand_int32 after init_genrand(1).
+	want := []uint64{1791095845, 4282876139, 3093770124, 4005303368, 491263}
 	mt := common.NewMT19937(1)
 	for i := 0; i < 10; i++ {
-		fmt.Println(mt.Extract())
+		v := mt.Extract()
+		fmt.Fprintln(w, v)
+		if i < len(want) && v != want[i] {
+			return fmt.Errorf("output %d is %v; want %v", i, v, want[i])
+		}
 	}
+	return nil
 }
@@ -3,30 +3,37 @@
 // found in the LICENSE file.
 
 // Crack an MT19937 seed
-package main
+package challenge22
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
 	"time"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
+func init() {
+	challenges.Register(challenges.Challenge{Num: 22, Title: "Crack an MT19937 seed", Run: Run})
+}
+
 func randDuration(min, max time.Duration) time.Duration {
 	return min + time.Duration(common.RandInt64(int64(max-min)))
 }
 
 // getRand simulates sleeping 40-1000 seconds, generating a pseudorandom number using the
 // "current" Unix timestamp as a seed, and then sleeping another 40-1000 seconds.
-// The number and total amount of time slept are returned.
-func getRand() (uint64, time.Duration) {
+// The number, the seed (for checking the answer), and total amount of time slept are returned.
+func getRand() (num, seed uint64, slept time.Duration) {
 	dur := randDuration(40*time.Second, 1000*time.Second)
-	num := common.NewMT19937(uint64(time.Now().Add(dur).Unix())).Extract()
-	return num, dur + randDuration(40*time.Second, 1000*time.Second)
+	seed = uint64(time.Now().Add(dur).Unix())
+	num = common.NewMT19937(seed).Extract()
+	return num, seed, dur + randDuration(40*time.Second, 1000*time.Second)
 }
 
-func main() {
+func Run(ctx context.Context, w io.Writer) error {
 	// From the challenge:
 	//
 	//   Write a routine that performs the following operation:
@@ -36,15 +43,15 @@ func main() {
 	//   * Returns the first 32 bit output of the RNG.
 
 	start := time.Now()
-	num, dur := getRand()
+	num, want, dur := getRand()
 	end := start.Add(dur)
 
-	for t := start; t.Before(end); t = t.Add(time.Second) {
-		seed := uint64(t.Unix())
-		if n := common.NewMT19937(seed).Extract(); n == num {
-			fmt.Printf("Seed %v produces %v\n", seed, num)
-			os.Exit(0)
-		}
+	// Check every second in the window in which the generator could have been seeded.
+	seed, _, err := common.BruteForceMTSeed(ctx, common.MT19937Params, []uint64{num},
+		uint64(start.Unix()), uint64(end.Unix()), nil)
+	if err != nil {
+		return fmt.Errorf("didn't find seed producing %v: %v", num, err)
 	}
-	panic(fmt.Sprintf("didn't find seed between %v and %v producing %v", start.Unix(), end.Unix(), num))
+	fmt.Fprintf(w, "Seed %v produces %v\n", seed, num)
+	return challenges.Check("seed", seed, want)
 }
@@ -3,20 +3,25 @@
 // found in the LICENSE file.
 
 // Create the MT19937 stream cipher and break it
-package main
+package challenge24
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"time"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
+func init() {
+	challenges.Register(challenges.Challenge{Num: 24, Title: "Create the MT19937 stream cipher and break it", Run: Run})
+}
+
 // process initializes an MT19937 PRNG with seed and repeatedly XORs its output against plain.
 // Each 32-bit output from the PRNG is interpreted in big-endian order.
 func process(seed uint64, plain []byte) []byte {
@@ -41,21 +46,21 @@ func process(seed uint64, plain []byte) []byte {
 	}
 }
 
-func testProcess() {
+func testProcess() error {
 	const (
 		seed  = 31337
 		plain = "This is the plaintext. Woo!"
 	)
 	enc := process(seed, []byte(plain))
 	dec := process(seed, enc)
-	if string(dec) != plain {
-		panic(fmt.Sprintf("Decrypted to %q; want %q\n", dec, plain))
-	}
+	return challenges.Check("decrypted text", string(dec), plain)
 }
 
-func main() {
+func Run(ctx context.Context, w io.Writer) error {
 	// Check that process() is reversible.
-	testProcess()
+	if err := testProcess(); err != nil {
+		return err
+	}
 
 	// Per the challenge: use a 16-bit seed to encrypt a known string
 	// preceded by a random number of random bytes.
@@ -69,13 +74,13 @@ func main() {
 	for s := 0; s < 1<<16; s++ {
 		b := process(uint64(s), enc)
 		if bytes.Contains(b, known) {
-			log.Println("Found seed:", s)
+			fmt.Fprintln(w, "Found seed:", s)
 			found = b[:len(b)-len(known)]
 			break
 		}
 	}
-	if !bytes.Equal(found, prefix) {
-		panic(fmt.Sprintf("Found prefix %v; want %v\n", found, prefix))
+	if err := challenges.Check("prefix", found, prefix); err != nil {
+		return err
 	}
 
 	// Per the challenge:
@@ -111,13 +116,14 @@ func main() {
 	}
 
 	if token := makeToken(time.Now().Add(-time.Hour)); tokenValid(token) {
-		fmt.Printf("Detected %q as seeded by time\n", hex.EncodeToString(token))
+		fmt.Fprintf(w, "Detected %q as seeded by time\n", hex.EncodeToString(token))
 	} else {
-		panic(fmt.Sprintf("Failed to detect %q as seeded by time\n", hex.EncodeToString(token)))
+		return fmt.Errorf("failed to detect %q as seeded by time", hex.EncodeToString(token))
 	}
 	if token := common.RandBytes(tokenLen); tokenValid(token) {
-		panic(fmt.Sprintf("Incorrectly detected %q as seeded by time\n", hex.EncodeToString(token)))
+		return fmt.Errorf("incorrectly detected %q as seeded by time", hex.EncodeToString(token))
 	} else {
-		fmt.Printf("Detected %q as not seeded by time\n", hex.EncodeToString(token))
+		fmt.Fprintf(w, "Detected %q as not seeded by time\n", hex.EncodeToString(token))
 	}
+	return nil
 }
@@ -3,15 +3,19 @@
 // found in the LICENSE file.
 
 // CTR bitflipping
-package main
+package challenge26
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
@@ -20,17 +24,12 @@ const (
 	suffix = ";comment2=%20like%20a%20pound%20of%20bacon"
 )
 
-var key []byte = common.RandBytes(16)
-var nonce uint64
-
 func init() {
-	if err := binary.Read(bytes.NewReader(common.RandBytes(8)), binary.LittleEndian, &nonce); err != nil {
-		panic(err)
-	}
+	challenges.Register(challenges.Challenge{Num: 26, Title: "CTR bitflipping", Run: Run})
 }
 
 // encrypt adds prefix and suffix to s and encrypts using AES-128 in CTR mode.
-func encrypt(s string) []byte {
+func encrypt(s string, key []byte, nonce uint64) []byte {
 	s = strings.ReplaceAll(s, ";", "%3B")
 	s = strings.ReplaceAll(s, "=", "%3D")
 	plain := prefix + s + suffix
@@ -43,7 +42,7 @@ func encrypt(s string) []byte {
 }
 
 // admin decrypts b and returns true if the resulting string contains ";admin=true;".
-func admin(b []byte) bool {
+func admin(b, key []byte, nonce uint64) bool {
 	var dec bytes.Buffer
 	if err := common.NewCTR(key, nonce).Process(bytes.NewReader(b), &dec); err != nil {
 		panic(err)
@@ -51,11 +50,14 @@ func admin(b []byte) bool {
 	return bytes.Contains(dec.Bytes(), []byte(";admin=true;"))
 }
 
-func main() {
+func Run(ctx context.Context, w io.Writer) error {
+	key := common.RandBytes(16)
+	nonce := binary.LittleEndian.Uint64(common.RandBytes(8))
+
 	// Determine the starting position of the text that we can modify.
 	plen := -1
-	a := encrypt("A")
-	b := encrypt("B")
+	a := encrypt("A", key, nonce)
+	b := encrypt("B", key, nonce)
 	for i := 0; i < len(a); i++ {
 		if a[i] != b[i] {
 			plen = i
@@ -63,9 +65,9 @@ func main() {
 		}
 	}
 	if plen < 0 {
-		panic("didn't find prefix length")
+		return errors.New("didn't find prefix length")
 	}
-	fmt.Println("Prefix has length", plen)
+	fmt.Fprintln(w, "Prefix has length", plen)
 
 	// CTR seems pretty awful in any case where we can force processing multiple times from the beginning of the stream!
 	// The plaintext gets XORed with the keystream, so all we need to do is encrypt once using placeholders for the ';'
@@ -74,13 +76,13 @@ func main() {
 	// The ability to modify individual bytes in the ciphertext in isolation, without affecting how other bytes get
 	// decrypted, seems generally problematic: even if we couldn't use simple XORs here, testing all combinations of
 	// these three bytes in the ciphertext would be feasible (256**3 = ~16 million).
-	enc := encrypt("foo\x00admin\x00true\x00")
+	enc := encrypt("foo\x00admin\x00true\x00", key, nonce)
 	enc[plen+3] ^= ';'
 	enc[plen+9] ^= '='
 	enc[plen+14] ^= ';'
-	if admin(enc) {
-		fmt.Println("Got admin with ciphertext", hex.EncodeToString(enc))
-	} else {
-		panic("Didn't get admin")
+	if !admin(enc, key, nonce) {
+		return errors.New("didn't get admin")
 	}
+	fmt.Fprintln(w, "Got admin with ciphertext", hex.EncodeToString(enc))
+	return nil
 }
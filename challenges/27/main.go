@@ -3,25 +3,34 @@
 // found in the LICENSE file.
 
 // Recover the key from CBC with IV=Key
-package main
+package challenge27
 
 import (
 	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-var key []byte = common.RandBytes(16)
-var iv = key // reuse key as IV
+//go:embed data.txt
+var data string
+
+func init() {
+	challenges.Register(challenges.Challenge{Num: 27, Title: "Recover the key from CBC with IV=Key", Run: Run})
+}
 
 // encrypt encrypts b using AES-128 in CBC mode using the key as the IV.
-func encrypt(plain []byte) []byte {
+func encrypt(plain, key []byte) []byte {
 	padded := common.PadPKCS7(plain, 16)
-	return common.EncryptAES(padded, key, iv)
+	return common.EncryptAES(padded, key, key)
 }
 
 // check decrypts enc and checks the resulting plaintext. From the challenge:
@@ -29,8 +38,8 @@ func encrypt(plain []byte) []byte {
 //   Verify each byte of the plaintext for ASCII compliance (ie, look for high-ASCII values). Noncompliant messages
 //   should raise an exception or return an error that includes the decrypted plaintext (this happens all the time in
 //   real systems, for what it's worth).
-func check(enc []byte) error {
-	plain, err := common.UnpadPKCS7(common.DecryptAES(enc, key, iv))
+func check(enc, key []byte) error {
+	plain, err := common.UnpadPKCS7(common.DecryptAES(enc, key, key))
 	if err != nil {
 		return errors.New("failed unpadding")
 	}
@@ -42,15 +51,16 @@ func check(enc []byte) error {
 	return nil
 }
 
-func main() {
+func Run(ctx context.Context, w io.Writer) error {
 	const bs = 16
+	key := common.RandBytes(bs)
 
 	// It's pretty weird that this challenge spells out all the steps to take.
 
 	// From the challenge:
 	//   Use your code to encrypt a message that is at least 3 blocks long:
 	//   AES-CBC(P_1, P_2, P_3) -> C_1, C_2, C_3
-	enc := encrypt(bytes.Repeat([]byte{255}, 4*bs)) // add a fourth block so padding block is preserved after modification
+	enc := encrypt(bytes.Repeat([]byte{255}, 4*bs), key) // add a fourth block so padding block is preserved after modification
 
 	// From the challenge:
 	//   Modify the message (you are now the attacker):
@@ -63,18 +73,18 @@ func main() {
 	//   Decrypt the message (you are now the receiver) and raise the appropriate error if high-ASCII is found.
 	//   As the attacker, recovering the plaintext from the error, extract the key:
 	//   P'_1 XOR P'_3
-	err := check(mod)
+	err := check(mod, key)
 	if err == nil {
-		panic("didn't get error")
+		return errors.New("didn't get error")
 	}
 	es := err.Error()
 	if !strings.Contains(es, "found non-ASCII byte") {
-		panic(fmt.Sprintf("got unexpected error %q", es))
+		return fmt.Errorf("got unexpected error %q", es)
 	}
 	ef := strings.Fields(es)
 	dec, err := hex.DecodeString(ef[len(ef)-1])
 	if err != nil {
-		panic(fmt.Sprint("failed to parse plaintext: ", err))
+		return fmt.Errorf("failed to parse plaintext: %v", err)
 	}
 
 	// During CBC decryption, plaintext block N is XORed with ciphertext block N-1.
@@ -84,13 +94,21 @@ func main() {
 	b1 := dec[:bs]
 	b3 := dec[2*bs : 3*bs]
 	rkey := common.XOR(b1, b3)
+	if err := challenges.Check("recovered key", rkey, key); err != nil {
+		return err
+	}
 
 	// Now check that we're able to use the recovered key/IV to decrypt ciphertext encrypted using the
 	// original key. (This isn't part of the challenge.)
-	enc = encrypt(common.ReadBase64("data.txt"))
-	if plain, err := common.UnpadPKCS7(common.DecryptAES(enc, rkey, rkey)); err != nil {
-		panic(err)
-	} else {
-		fmt.Printf("%q\n", plain)
+	secret, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
+	enc = encrypt(secret, key)
+	plain, err := common.UnpadPKCS7(common.DecryptAES(enc, rkey, rkey))
+	if err != nil {
+		return err
 	}
+	fmt.Fprintf(w, "%q\n", plain)
+	return challenges.Check("plaintext", plain, secret)
 }
@@ -3,15 +3,24 @@
 // found in the LICENSE file.
 
 // Implement a SHA-1 keyed MAC
-package main
+package challenge28
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 	"github.com/derat/cryptopals/sha1"
 )
 
+func init() {
+	challenges.Register(challenges.Challenge{Num: 28, Title: "Implement a SHA-1 keyed MAC", Run: Run})
+}
+
 // From the challenge:
 //
 //   Write a function to authenticate a message under a secret key by using a secret-prefix MAC, which is simply:
@@ -27,7 +36,7 @@ func verify(msg, mac, key []byte) bool {
 	return bytes.Equal(sign(msg, key), mac)
 }
 
-func main() {
+func Run(ctx context.Context, w io.Writer) error {
 	// From the challenge:
 	//
 	//   Verify that you cannot tamper with the message without breaking the MAC you've produced, and that you can't
@@ -37,26 +46,28 @@ func main() {
 	const orig = "This is a test"
 	mac := sign([]byte(orig), skey)
 	if !verify([]byte(orig), mac, skey) {
-		panic("failed verifying MAC")
+		return errors.New("failed verifying MAC")
 	}
 
 	mod := []byte(orig)
 	mod[0] = 'A'
 	if verify(mod, mac, skey) {
-		panic("was able to reuse MAC with modified message")
+		return errors.New("was able to reuse MAC with modified message")
 	}
 
 	copy(mod, []byte(orig))
 	mod = append(mod, 'A')
 	if verify(mod, mac, skey) {
-		panic("was able to reuse MAC with appended message")
+		return errors.New("was able to reuse MAC with appended message")
 	}
 
 	if verify(mod, sign(mac, nil), skey) {
-		panic("was able to verify using MAC generated without key")
+		return errors.New("was able to verify using MAC generated without key")
 	}
 
 	if verify(mod, sign(mac, common.A(16)), skey) {
-		panic("was able to verify using MAC generated with incorrect key")
+		return errors.New("was able to verify using MAC generated with incorrect key")
 	}
+	fmt.Fprintf(w, "MAC %x couldn't be forged\n", mac)
+	return nil
 }
@@ -3,28 +3,33 @@
 // found in the LICENSE file.
 
 // Break an MD4 keyed MAC using length extension
-package main
+package challenge30
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 	"github.com/derat/cryptopals/md4"
 )
 
-var key []byte    // randomly chosen from /usr/share/dict/words
-var maxKeyLen int // maximum potential key length
-
 func init() {
-	word, ml := common.RandWord()
-	key = []byte(word)
-	maxKeyLen = ml
+	challenges.Register(challenges.Challenge{Num: 30, Title: "Break an MD4 keyed MAC using length extension", Run: Run})
+}
+
+// randKey returns a random secret key and the maximum length of potential keys.
+// Per challenge 30, the key is a random word from a dictionary.
+func randKey() (key []byte, maxKeyLen int) {
+	word, maxLen := common.RandWord()
+	return []byte(word), maxLen
 }
 
 // sign prepends the secret key to msg and returns an MD4 hash of the resulting buffer.
-func sign(msg []byte) []byte {
+func sign(msg, key []byte) []byte {
 	concat := append([]byte{}, key...)
 	concat = append(concat, msg...)
 	h := md4.New()
@@ -34,59 +39,43 @@ func sign(msg []byte) []byte {
 }
 
 // verify returns true if mac appears to have been generated by passing msg to sign().
-func verify(msg, mac []byte) bool {
-	return bytes.Equal(sign(msg), mac)
+func verify(msg, mac, key []byte) bool {
+	return bytes.Equal(sign(msg, key), mac)
 }
 
-func main() {
-	const omsg = "comment1=cooking%20MCs;userdata=foo;comment2=%20like%20a%20pound%20of%20bacon"
-	omac := sign([]byte(omsg))
-	if !verify([]byte(omsg), omac) {
-		panic("failed verifying original MAC")
-	}
+func Run(ctx context.Context, w io.Writer) error {
+	key, maxKeyLen := randKey()
 
-	// MD4 looks like it uses little-endian numbers rather than big-endian.
-	// This is backed up by
-	// https://en.wikipedia.org/wiki/Comparison_of_cryptographic_hash_functions#Compression_function.
-	var state [4]uint32
-	if err := binary.Read(bytes.NewReader(omac), binary.LittleEndian, state[:]); err != nil {
-		panic(fmt.Sprintf("failed reading MAC %x as state: %v", omac, err))
+	const omsg = "comment1=cooking%20MCs;userdata=foo;comment2=%20like%20a%20pound%20of%20bacon"
+	omac := sign([]byte(omsg), key)
+	if !verify([]byte(omsg), omac, key) {
+		return errors.New("failed verifying original MAC")
 	}
 
 	const extra = ";admin=true"
 	admin := false
 	for kl := 0; kl <= maxKeyLen; kl++ {
-		// First, figure out total message length used to generate the original hash.
 		// The amount of padding is dependent on the (unknown) length of the key
-		// and the (known) length of the original message.
-		olen := kl + len(omsg)
-		pad := common.MDPadding(olen, binary.LittleEndian)
-
-		// Initialize a new hash.Hash and write the original message length's worth of
-		// data to it, including the unknown key and the padding. The content doesn't matter,
-		// since we're going to inject the state that resulted after generating the original MAC.
-		// This just sets up the hash's other internal variables (e.g. message length).
-		h := md4.New()
-		h.Write(common.A(olen + len(pad)))
-
-		// Restore the state that the hash was in when the original MAC was generated (which
-		// is just the original MAC itself) and then add our extra data.
-		md4.SetState(h, state)
-		h.Write([]byte(extra))
-		mac := h.Sum(nil)
+		// and the (known) length of the original message. See common.LengthExtend
+		// for a description of the attack.
+		mac, pad, err := common.LengthExtend(common.MD4, omac, kl+len(omsg), []byte(extra))
+		if err != nil {
+			return err
+		}
 
 		// If this was the correct key length, we should now have a MAC corresponding to the
 		// original message, plus the padding, plus our extra data.
 		msg := append([]byte(omsg), pad...)
 		msg = append(msg, []byte(extra)...)
-		if verify(msg, mac[:]) {
-			fmt.Printf("Generated valid MAC %x for %q using key length %v\n", mac, msg, kl)
-			fmt.Printf("This attack isn't capable of getting the key, but it was %q\n", key)
+		if verify(msg, mac[:], key) {
+			fmt.Fprintf(w, "Generated valid MAC %x for %q using key length %v\n", mac, msg, kl)
+			fmt.Fprintf(w, "This attack isn't capable of getting the key, but it was %q\n", key)
 			admin = true
 			break
 		}
 	}
 	if !admin {
-		panic("Didn't get MAC")
+		return errors.New("didn't get MAC")
 	}
+	return nil
 }
@@ -3,21 +3,36 @@
 // found in the LICENSE file.
 
 // Implement and break HMAC-SHA1 with an artificial timing leak
-package main
+package challenge31
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sort"
 	"time"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-const hmacLen = 20 // hardcoded for SHA-1
+const (
+	hmacLen   = 20 // hardcoded for SHA-1
+	fastBytes = 4  // number of HMAC bytes to recover when challenges.Options.Fast is set
+)
+
+func init() {
+	challenges.Register(challenges.Challenge{
+		Num:    31,
+		Title:  "Implement and break HMAC-SHA1 with an artificial timing leak",
+		Run:    Run,
+		Timing: true,
+	})
+}
 
 // insecureCompare compares a and b one byte at a time.
 // It sleeps 50 milliseconds after each successful comparison and returns immediately
@@ -32,63 +47,110 @@ func insecureCompare(a, b []byte) bool {
 	return len(a) == len(b)
 }
 
+// post sends a POST request to url and returns the response's status code.
+func post(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &bytes.Buffer{})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request to %v failed: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// measure sends n requests to url and returns the shortest time taken.
+func measure(ctx context.Context, url string, n int) (time.Duration, error) {
+	min := time.Hour
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		if _, err := post(ctx, url); err != nil {
+			return 0, err
+		}
+		if d := time.Now().Sub(start); d < min {
+			min = d
+		}
+	}
+	return min, nil
+}
+
 // getNextByte performs a timing attack to get the next byte of the HMAC.
 // The hex-encoded HMAC will be appended to the end of urlPrefix.
-func getNextByte(urlPrefix string, known []byte) byte {
+func getNextByte(ctx context.Context, urlPrefix string, known []byte) (byte, error) {
 	type result struct {
-		b byte
-		d time.Duration
+		b   byte
+		d   time.Duration
+		err error
 	}
-	todo := make(chan []byte, 256) // HMACs to test
+	todo := make(chan byte, 256)   // next bytes to test
 	done := make(chan result, 256) // test results
 
+	// makeURL returns the URL for an HMAC consisting of known followed by b.
+	makeURL := func(b byte) string {
+		hmac := make([]byte, hmacLen)
+		copy(hmac, known)
+		hmac[len(known)] = b
+		return urlPrefix + hex.EncodeToString(hmac)
+	}
+
 	// Start some goroutines to test HMACs in parallel.
 	const numGoroutines = 32
 	for i := 0; i < numGoroutines; i++ {
 		go func() {
-			for hmac := range todo {
-				url := urlPrefix + hex.EncodeToString(hmac)
-				start := time.Now()
-				if resp, err := http.Post(url, "text/plain", &bytes.Buffer{}); err != nil {
-					panic(fmt.Sprintf("request to %v failed: %v", url, err))
-				} else {
-					// TODO: Check resp.StatusCode? It'll always be 500 until the end, though.
-					delay := time.Now().Sub(start)
-					done <- result{hmac[len(known)], delay}
-					resp.Body.Close()
-				}
+			for b := range todo {
+				// The status will always be 500 until the end, so don't bother checking it.
+				d, err := measure(ctx, makeURL(b), 1)
+				done <- result{b, d, err}
 			}
 		}()
 	}
 
-	// Generate HMACs using all possible next bytes.
+	// Test all possible next bytes.
 	for i := 0; i < 256; i++ {
-		hmac := make([]byte, hmacLen)
-		copy(hmac, known)
-		hmac[len(known)] = byte(i)
-		todo <- hmac
+		todo <- byte(i)
 	}
+	close(todo)
 
-	// Wait on the results and choose the one that took the longest.
-	var maxByte byte
-	var maxDelay time.Duration
+	// Wait on the results and sort them from slowest to fastest.
+	results := make([]result, 0, 256)
 	for i := 0; i < 256; i++ {
 		res := <-done
-		if res.d > maxDelay {
+		if res.err != nil {
+			return 0, res.err
+		}
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].d > results[j].d })
+
+	// The parallel requests contend with each other (and with anything else running on
+	// the machine), so the slowest byte isn't always the correct one. Measure the
+	// slowest few bytes again one at a time and choose the slowest.
+	const (
+		numFinalists   = 4
+		numFinalChecks = 3
+	)
+	var maxByte byte
+	var maxDelay time.Duration
+	for _, res := range results[:numFinalists] {
+		d, err := measure(ctx, makeURL(res.b), numFinalChecks)
+		if err != nil {
+			return 0, err
+		}
+		if d > maxDelay {
 			maxByte = res.b
-			maxDelay = res.d
+			maxDelay = d
 		}
 	}
-	return maxByte
+	return maxByte, nil
 }
 
-func main() {
-	var (
-		key  = common.RandBytes(1 + common.RandInt(64))
-		addr = "127.0.0.1:8345"
-	)
+func Run(ctx context.Context, w io.Writer) error {
+	key := common.RandBytes(1 + common.RandInt(64))
 
-	http.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
 		file := r.FormValue("file")
 		sig := r.FormValue("signature")
 
@@ -108,35 +170,43 @@ func main() {
 		io.WriteString(w, "ok\n")
 	})
 
-	go http.ListenAndServe(addr, nil)
-
-	// Wait for the server to start.
-	for {
-		if conn, err := net.DialTimeout("tcp", addr, 5*time.Second); err == nil {
-			conn.Close()
-			break
-		}
-		time.Sleep(10 * time.Millisecond)
+	// Listen on an arbitrary port.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
 	}
+	srv := http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Close()
 
 	const file = "filename.txt"
-	url := fmt.Sprintf("http://%v/test?file=%v&signature=", addr, file)
+	url := fmt.Sprintf("http://%v/test?file=%v&signature=", ln.Addr(), file)
 
+	// In fast mode, just recover the first few bytes and compare them against the real HMAC.
+	n := hmacLen
+	if challenges.GetOptions(ctx).Fast {
+		n = fastBytes
+	}
 	var hmac []byte
-	for len(hmac) < hmacLen {
-		hmac = append(hmac, getNextByte(url, hmac))
-		fmt.Printf("HMAC: %x\n", hmac)
+	for len(hmac) < n {
+		b, err := getNextByte(ctx, url, hmac)
+		if err != nil {
+			return err
+		}
+		hmac = append(hmac, b)
+		fmt.Fprintf(w, "HMAC: %x\n", hmac)
+	}
+	if n < hmacLen {
+		return challenges.Check("HMAC prefix", hmac, common.HMACSHA1([]byte(file), key)[:n])
 	}
-	fmt.Printf("Constructed HMAC %x for file %q\n", hmac, file)
+	fmt.Fprintf(w, "Constructed HMAC %x for file %q\n", hmac, file)
 
 	url += hex.EncodeToString(hmac)
-	resp, err := http.Post(url, "text/plain", &bytes.Buffer{})
-	if err != nil {
-		panic(fmt.Sprintf("request to %v failed: %v", url, err))
-	} else if resp.StatusCode != 200 {
-		panic(fmt.Sprintf("request to %v returned %v", url, resp.Status))
-	} else {
-		fmt.Println("HMAC works!")
+	if code, err := post(ctx, url); err != nil {
+		return err
+	} else if code != http.StatusOK {
+		return fmt.Errorf("request to %v returned %v", url, code)
 	}
-	resp.Body.Close()
+	fmt.Fprintln(w, "HMAC works!")
+	return nil
 }
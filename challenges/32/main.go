@@ -3,21 +3,36 @@
 // found in the LICENSE file.
 
 // Break HMAC-SHA1 with a slightly less artificial timing leak
-package main
+package challenge32
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sort"
 	"time"
 
+	"github.com/derat/cryptopals/challenges"
 	"github.com/derat/cryptopals/common"
 )
 
-const hmacLen = 20 // hardcoded for SHA-1
+const (
+	hmacLen   = 20 // hardcoded for SHA-1
+	fastBytes = 4  // number of HMAC bytes to recover when challenges.Options.Fast is set
+)
+
+func init() {
+	challenges.Register(challenges.Challenge{
+		Num:    32,
+		Title:  "Break HMAC-SHA1 with a slightly less artificial timing leak",
+		Run:    Run,
+		Timing: true,
+	})
+}
 
 // insecureCompare compares a and b one byte at a time.
 // It sleeps 5 milliseconds after each successful comparison and returns immediately
@@ -32,72 +47,125 @@ func insecureCompare(a, b []byte) bool {
 	return len(a) == len(b)
 }
 
-// getNextByte performs a timing attack to get the next byte of the HMAC.
-// The hex-encoded HMAC will be appended to the end of urlPrefix.
-func getNextByte(urlPrefix string, known []byte) byte {
+// post sends a POST request to url and returns the response's status code.
+func post(ctx context.Context, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &bytes.Buffer{})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request to %v failed: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// measure sends n requests to url and returns the shortest time taken.
+func measure(ctx context.Context, url string, n int) (time.Duration, error) {
+	min := time.Hour
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		if _, err := post(ctx, url); err != nil {
+			return 0, err
+		}
+		if d := time.Now().Sub(start); d < min {
+			min = d
+		}
+	}
+	return min, nil
+}
+
+// measureAll measures the time taken by requests for each of cands (see measure)
+// using parallel goroutines. cands is sorted from slowest to fastest.
+func measureAll(ctx context.Context, cands []byte, makeURL func(byte) string, n int) error {
 	type result struct {
-		b byte
-		d time.Duration
+		b   byte
+		d   time.Duration
+		err error
 	}
-	todo := make(chan []byte, 256) // HMACs to test
-	done := make(chan result, 256) // test results
+	todo := make(chan byte, len(cands))
+	done := make(chan result, len(cands))
 
-	// Start some goroutines to test HMACs in parallel.
-	const (
-		numGoroutines = 24
-		numChecks     = 5
-	)
+	const numGoroutines = 24
 	for i := 0; i < numGoroutines; i++ {
 		go func() {
-			for hmac := range todo {
-				url := urlPrefix + hex.EncodeToString(hmac)
-
-				minDelay := time.Hour
-				for j := 0; j < numChecks; j++ {
-					start := time.Now()
-					if resp, err := http.Post(url, "text/plain", &bytes.Buffer{}); err != nil {
-						panic(fmt.Sprintf("request to %v failed: %v", url, err))
-					} else {
-						if delay := time.Now().Sub(start); delay < minDelay {
-							minDelay = delay
-						}
-						resp.Body.Close()
-					}
-				}
-
-				done <- result{hmac[len(known)], minDelay}
+			for b := range todo {
+				d, err := measure(ctx, makeURL(b), n)
+				done <- result{b, d, err}
 			}
 		}()
 	}
+	for _, b := range cands {
+		todo <- b
+	}
+	close(todo)
+
+	delays := make(map[byte]time.Duration, len(cands))
+	var err error
+	for range cands {
+		res := <-done
+		if res.err != nil {
+			err = res.err
+		}
+		delays[res.b] = res.d
+	}
+	sort.Slice(cands, func(i, j int) bool { return delays[cands[i]] > delays[cands[j]] })
+	return err
+}
 
-	// Generate HMACs using all possible next bytes.
-	for i := 0; i < 256; i++ {
+// getNextByte performs a timing attack to get the next byte of the HMAC.
+// The hex-encoded HMAC will be appended to the end of urlPrefix.
+func getNextByte(ctx context.Context, urlPrefix string, known []byte) (byte, error) {
+	// makeURL returns the URL for an HMAC consisting of known followed by b.
+	makeURL := func(b byte) string {
 		hmac := make([]byte, hmacLen)
 		copy(hmac, known)
-		hmac[len(known)] = byte(i)
-		todo <- hmac
+		hmac[len(known)] = b
+		return urlPrefix + hex.EncodeToString(hmac)
+	}
+
+	cands := make([]byte, 256)
+	for i := range cands {
+		cands[i] = byte(i)
 	}
 
-	// Wait on the results and choose the one that took the longest.
+	// The parallel requests contend with each other (and with anything else running on
+	// the machine), so the slowest byte isn't always the correct one. Repeatedly discard
+	// the faster half of the candidates until only a few are left.
+	const (
+		numChecks      = 5
+		numFinalists   = 4
+		numFinalChecks = 10
+	)
+	for len(cands) > numFinalists {
+		if err := measureAll(ctx, cands, makeURL, numChecks); err != nil {
+			return 0, err
+		}
+		cands = cands[:len(cands)/2]
+	}
+
+	// Measure the remaining bytes one at a time and choose the slowest.
 	var maxByte byte
 	var maxDelay time.Duration
-	for i := 0; i < 256; i++ {
-		res := <-done
-		if res.d > maxDelay {
-			maxByte = res.b
-			maxDelay = res.d
+	for _, b := range cands {
+		d, err := measure(ctx, makeURL(b), numFinalChecks)
+		if err != nil {
+			return 0, err
+		}
+		if d > maxDelay {
+			maxByte = b
+			maxDelay = d
 		}
 	}
-	return maxByte
+	return maxByte, nil
 }
 
-func main() {
-	var (
-		key  = common.RandBytes(1 + common.RandInt(64))
-		addr = "127.0.0.1:8345"
-	)
+func Run(ctx context.Context, w io.Writer) error {
+	key := common.RandBytes(1 + common.RandInt(64))
 
-	http.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
 		file := r.FormValue("file")
 		sig := r.FormValue("signature")
 
@@ -117,38 +185,43 @@ func main() {
 		io.WriteString(w, "ok\n")
 	})
 
-	go http.ListenAndServe(addr, nil)
-
-	// Wait for the server to start.
-	for {
-		if conn, err := net.DialTimeout("tcp", addr, 5*time.Second); err == nil {
-			conn.Close()
-			break
-		}
-		time.Sleep(10 * time.Millisecond)
+	// Listen on an arbitrary port.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
 	}
+	srv := http.Server{Handler: mux}
+	go srv.Serve(ln)
+	defer srv.Close()
 
 	const file = "filename.txt"
-	url := fmt.Sprintf("http://%v/test?file=%v&signature=", addr, file)
+	url := fmt.Sprintf("http://%v/test?file=%v&signature=", ln.Addr(), file)
 
+	// In fast mode, just recover the first few bytes and compare them against the real HMAC.
+	n := hmacLen
+	if challenges.GetOptions(ctx).Fast {
+		n = fastBytes
+	}
 	var hmac []byte
-	for len(hmac) < hmacLen {
-		hmac = append(hmac, getNextByte(url, hmac))
-		fmt.Printf("HMAC: %x\n", hmac)
+	for len(hmac) < n {
+		b, err := getNextByte(ctx, url, hmac)
+		if err != nil {
+			return err
+		}
+		hmac = append(hmac, b)
+		fmt.Fprintf(w, "HMAC: %x\n", hmac)
 	}
-	fmt.Printf("Constructed HMAC %x for file %q\n", hmac, file)
+	if n < hmacLen {
+		return challenges.Check("HMAC prefix", hmac, common.HMACSHA1([]byte(file), key)[:n])
+	}
+	fmt.Fprintf(w, "Constructed HMAC %x for file %q\n", hmac, file)
 
-	// TODO: This doesn't get the correct HMAC every time, but it at least works sometimes.
-	// I could probably tweak the getNextByte() function further more to improve it, but it
-	// doesn't seem worthwhile -- I get the idea.
 	url += hex.EncodeToString(hmac)
-	resp, err := http.Post(url, "text/plain", &bytes.Buffer{})
-	if err != nil {
-		panic(fmt.Sprintf("request to %v failed: %v", url, err))
-	} else if resp.StatusCode != 200 {
-		panic(fmt.Sprintf("request to %v returned %v", url, resp.Status))
-	} else {
-		fmt.Println("HMAC works!")
+	if code, err := post(ctx, url); err != nil {
+		return err
+	} else if code != http.StatusOK {
+		return fmt.Errorf("request to %v returned %v", url, code)
 	}
-	resp.Body.Close()
+	fmt.Fprintln(w, "HMAC works!")
+	return nil
 }
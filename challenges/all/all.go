@@ -0,0 +1,41 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package all registers all of the challenges with the challenges package.
+package all
+
+import (
+	_ "github.com/derat/cryptopals/challenges/01"
+	_ "github.com/derat/cryptopals/challenges/02"
+	_ "github.com/derat/cryptopals/challenges/03"
+	_ "github.com/derat/cryptopals/challenges/04"
+	_ "github.com/derat/cryptopals/challenges/05"
+	_ "github.com/derat/cryptopals/challenges/06"
+	_ "github.com/derat/cryptopals/challenges/07"
+	_ "github.com/derat/cryptopals/challenges/08"
+	_ "github.com/derat/cryptopals/challenges/09"
+	_ "github.com/derat/cryptopals/challenges/10"
+	_ "github.com/derat/cryptopals/challenges/11"
+	_ "github.com/derat/cryptopals/challenges/12"
+	_ "github.com/derat/cryptopals/challenges/13"
+	_ "github.com/derat/cryptopals/challenges/14"
+	_ "github.com/derat/cryptopals/challenges/15"
+	_ "github.com/derat/cryptopals/challenges/16"
+	_ "github.com/derat/cryptopals/challenges/17"
+	_ "github.com/derat/cryptopals/challenges/18"
+	_ "github.com/derat/cryptopals/challenges/19"
+	_ "github.com/derat/cryptopals/challenges/20"
+	_ "github.com/derat/cryptopals/challenges/21"
+	_ "github.com/derat/cryptopals/challenges/22"
+	_ "github.com/derat/cryptopals/challenges/23"
+	_ "github.com/derat/cryptopals/challenges/24"
+	_ "github.com/derat/cryptopals/challenges/25"
+	_ "github.com/derat/cryptopals/challenges/26"
+	_ "github.com/derat/cryptopals/challenges/27"
+	_ "github.com/derat/cryptopals/challenges/28"
+	_ "github.com/derat/cryptopals/challenges/29"
+	_ "github.com/derat/cryptopals/challenges/30"
+	_ "github.com/derat/cryptopals/challenges/31"
+	_ "github.com/derat/cryptopals/challenges/32"
+)
@@ -0,0 +1,60 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package all
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/derat/cryptopals/challenges"
+	"github.com/derat/cryptopals/common"
+)
+
+var (
+	full = flag.Bool("full", false, "Run timing-attack challenges in full instead of in fast mode")
+	seed = flag.Int64("seed", 1, "Seed for deterministic keys and inputs (0 to choose one randomly)")
+)
+
+func TestChallenges(t *testing.T) {
+	const numChallenges = 32
+	all := challenges.All()
+	if len(all) != numChallenges {
+		t.Errorf("Got %v registered challenges; want %v", len(all), numChallenges)
+	}
+
+	// Always use a nonzero seed so that failures can be replayed with -seed.
+	opts := challenges.Options{Fast: !*full, Seed: *seed}
+	for opts.Seed == 0 {
+		opts.Seed = common.NewSecureRand().Int64(math.MaxInt64)
+	}
+	t.Logf("Using seed %d", opts.Seed)
+
+	for _, c := range all {
+		c := c
+		t.Run(fmt.Sprintf("%02d", c.Num), func(t *testing.T) {
+			if c.Timing && testing.Short() {
+				t.Skip("Skipping timing attack in short mode")
+			}
+			// The race detector slows down and adds jitter to the timing-attack
+			// clients and servers, making their measurements unreliable.
+			if c.Timing && raceEnabled {
+				t.Skip("Skipping timing attack with race detector enabled")
+			}
+			var out bytes.Buffer
+			d, err := challenges.Run(context.Background(), c, opts, &out)
+			if err != nil {
+				t.Errorf("Challenge %d (%s) failed with seed %d: %v\nOutput:\n%s",
+					c.Num, c.Title, opts.Seed, err, out.String())
+			} else {
+				t.Logf("Challenge %d passed in %v", c.Num, d.Round(time.Millisecond))
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build !race
+// +build !race
+
+package all
+
+// raceEnabled is true if the race detector is enabled.
+const raceEnabled = false
@@ -0,0 +1,124 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package challenges contains a registry of solutions to the Cryptopals challenges.
+//
+// Each challenge lives in its own numbered subpackage, which registers itself
+// when imported. Import github.com/derat/cryptopals/challenges/all to register
+// every challenge.
+package challenges
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/derat/cryptopals/common"
+)
+
+// Challenge describes a single challenge.
+type Challenge struct {
+	Num   int    // challenge number, e.g. 6
+	Title string // challenge title, e.g. "Break repeating-key XOR"
+	// Run solves the challenge, writing human-readable output to w.
+	// It returns an error if the challenge wasn't solved correctly.
+	Run func(ctx context.Context, w io.Writer) error
+	// Timing is true if the challenge performs a timing attack that
+	// takes a long time to run unless Options.Fast is set.
+	Timing bool
+}
+
+var (
+	registry   = make(map[int]Challenge)
+	registryMu sync.Mutex
+)
+
+// Register registers c. It should be called from the init function of c's package.
+// It panics if a challenge with the same number was already registered.
+func Register(c Challenge) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[c.Num]; ok {
+		panic(fmt.Sprintf("challenge %d registered twice", c.Num))
+	}
+	registry[c.Num] = c
+}
+
+// All returns all registered challenges, ordered by number.
+func All() []Challenge {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	all := make([]Challenge, 0, len(registry))
+	for _, c := range registry {
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Num < all[j].Num })
+	return all
+}
+
+// Get returns the challenge with the supplied number.
+func Get(num int) (c Challenge, ok bool) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	c, ok = registry[num]
+	return c, ok
+}
+
+// Options configures how challenges are run.
+type Options struct {
+	// Fast makes slow challenges (e.g. timing attacks) do less work,
+	// e.g. by only recovering part of a secret.
+	Fast bool
+	// Seed is passed to common.SeedRand before running the challenge, making its
+	// random keys and inputs deterministic. If zero, cryptographically-secure
+	// random data is used instead.
+	Seed int64
+}
+
+type optionsKey struct{}
+
+// GetOptions returns the Options passed to Run via ctx.
+func GetOptions(ctx context.Context) Options {
+	opts, _ := ctx.Value(optionsKey{}).(Options)
+	return opts
+}
+
+// Run runs c with the supplied options and writes its output to w.
+// The time taken by the challenge is returned.
+//
+// Since opts.Seed changes the randomness used throughout the common package,
+// challenges must not be run concurrently.
+func Run(ctx context.Context, c Challenge, opts Options, w io.Writer) (time.Duration, error) {
+	common.SeedRand(opts.Seed)
+	start := time.Now()
+	err := c.Run(context.WithValue(ctx, optionsKey{}, opts), w)
+	return time.Since(start), err
+}
+
+// Check returns an error describing the mismatch if got and want are not deeply equal.
+// what describes the value, e.g. "decrypted text".
+func Check(what string, got, want interface{}) error {
+	if gb, ok := got.([]byte); ok {
+		if wb, ok := want.([]byte); ok && bytes.Equal(gb, wb) {
+			return nil
+		}
+		return fmt.Errorf("%s is %q; want %q", what, got, want)
+	}
+	if !reflect.DeepEqual(got, want) {
+		if _, ok := got.(string); ok {
+			return fmt.Errorf("%s is %q; want %q", what, got, want)
+		}
+		return fmt.Errorf("%s is %v; want %v", what, got, want)
+	}
+	return nil
+}
+
+// FunkyMusic is the start of the plaintext used by several challenges
+// (e.g. 6, 7, 10, 25, and 27).
+const FunkyMusic = "I'm back and I'm ringin' the bell \nA rockin' on the mike while the fly girls yell \n"
@@ -0,0 +1,307 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Cribdrag interactively recovers plaintexts encrypted using a reused keystream,
+// e.g. CTR mode with a fixed nonce (as in challenges 19 and 20).
+//
+// Usage:
+//
+//	cribdrag [flags] <file>
+//
+// The file should contain one base64- or hex-encoded ciphertext per line.
+// Type "help" at the prompt for a list of commands.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/derat/cryptopals/common"
+)
+
+const helpText = `Commands:
+  show                 redraw the decryptions
+  crib TEXT            drag TEXT across every row and list the best placements
+  use N                apply placement N from the last crib listing
+  set ROW COL TEXT     fix the plaintext of ROW starting at column COL to TEXT
+  key COL HEX          set the keystream byte at column COL
+  refine               replace the keystream with common.BreakFixedNonceCTR's guess
+  reset                restore the initial per-column SingleByteXOR guess
+  undo                 undo the last change
+  help                 show this message
+  quit                 exit
+TEXT may be a Go-quoted string, e.g. "foo\x00".`
+
+func main() {
+	hexFlag := flag.Bool("hex", false, "Input lines are hex-encoded rather than base64")
+	clearFlag := flag.Bool("clear", true, "Clear the terminal before redrawing")
+	numFlag := flag.Int("placements", 10, "Number of crib placements to list")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flag]... <file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	read := common.TryReadBase64Lines
+	if *hexFlag {
+		read = common.TryReadHexLines
+	}
+	cts, err := read(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed reading ciphertexts:", err)
+		os.Exit(1)
+	}
+	if len(cts) == 0 {
+		fmt.Fprintln(os.Stderr, "No ciphertexts in", flag.Arg(0))
+		os.Exit(1)
+	}
+
+	s := newSession(cts)
+	s.clear = *clearFlag
+	s.numPlacements = *numFlag
+	s.run(os.Stdin, os.Stdout)
+}
+
+// state is a snapshot of a session's keystream guess, saved for undo.
+type state struct {
+	ks    []byte
+	fixed []bool
+}
+
+// session holds the state of an interactive session.
+type session struct {
+	cts           [][]byte
+	ks            []byte  // current keystream guess
+	initial       []byte  // initial keystream guess from SingleByteXOR
+	fixed         []bool  // columns whose keystream bytes were set by the user
+	history       []state // previous states, for undo
+	placements    []common.CribPlacement
+	numPlacements int
+	clear         bool
+}
+
+func newSession(cts [][]byte) *session {
+	maxLen := 0
+	for _, ct := range cts {
+		if len(ct) > maxLen {
+			maxLen = len(ct)
+		}
+	}
+	s := &session{cts: cts, initial: make([]byte, maxLen), fixed: make([]bool, maxLen)}
+	col := make([]byte, 0, len(cts))
+	for i := range s.initial {
+		col = col[:0]
+		for _, ct := range cts {
+			if i < len(ct) {
+				col = append(col, ct[i])
+			}
+		}
+		s.initial[i] = common.SingleByteXOR(col)
+	}
+	s.ks = append([]byte{}, s.initial...)
+	return s
+}
+
+// run reads commands from r until EOF or "quit", writing output to w.
+func (s *session) run(r io.Reader, w io.Writer) {
+	s.render(w, "")
+	sc := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(w, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(w)
+			return
+		}
+		msg, quit := s.handle(strings.TrimSpace(sc.Text()))
+		if quit {
+			return
+		}
+		s.render(w, msg)
+	}
+}
+
+// handle executes a single command line. A message to display after redrawing is
+// returned, along with true if the session should end.
+func (s *session) handle(line string) (msg string, quit bool) {
+	cmd, rest := line, ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		cmd, rest = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch cmd {
+	case "", "show":
+		return "", false
+	case "help", "?":
+		return helpText, false
+	case "quit", "exit", "q":
+		return "", true
+	case "crib":
+		crib, err := parseText(rest)
+		if err != nil || len(crib) == 0 {
+			return "Usage: crib TEXT", false
+		}
+		s.placements = common.DragCrib(s.cts, crib, nil)
+		if len(s.placements) > s.numPlacements {
+			s.placements = s.placements[:s.numPlacements]
+		}
+		return s.placementList(crib), false
+	case "use":
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 || n >= len(s.placements) {
+			return fmt.Sprintf("Need placement in [0, %d)", len(s.placements)), false
+		}
+		p := s.placements[n]
+		s.setKeystream(p.Pos, p.Keystream)
+		return fmt.Sprintf("Applied placement %d (row %d, column %d)", n, p.Row, p.Pos), false
+	case "set":
+		f := strings.SplitN(rest, " ", 3)
+		if len(f) != 3 {
+			return "Usage: set ROW COL TEXT", false
+		}
+		row, rerr := strconv.Atoi(f[0])
+		col, cerr := strconv.Atoi(f[1])
+		text, terr := parseText(f[2])
+		if rerr != nil || cerr != nil || terr != nil || row < 0 || row >= len(s.cts) || col < 0 {
+			return "Usage: set ROW COL TEXT", false
+		}
+		ct := s.cts[row]
+		if col+len(text) > len(ct) {
+			return fmt.Sprintf("Row %d is only %d bytes long", row, len(ct)), false
+		}
+		s.setKeystream(col, common.XOR(ct[col:col+len(text)], text))
+		return "", false
+	case "key":
+		f := strings.Fields(rest)
+		if len(f) != 2 {
+			return "Usage: key COL HEX", false
+		}
+		col, cerr := strconv.Atoi(f[0])
+		b, berr := strconv.ParseUint(f[1], 16, 8)
+		if cerr != nil || berr != nil || col < 0 || col >= len(s.ks) {
+			return "Usage: key COL HEX", false
+		}
+		s.setKeystream(col, []byte{byte(b)})
+		return "", false
+	case "refine":
+		s.save()
+		ks, _ := common.BreakFixedNonceCTR(s.cts)
+		// Keep bytes that were set by the user.
+		for i := range ks {
+			if !s.fixed[i] {
+				s.ks[i] = ks[i]
+			}
+		}
+		return "Refined unfixed columns", false
+	case "reset":
+		s.save()
+		copy(s.ks, s.initial)
+		for i := range s.fixed {
+			s.fixed[i] = false
+		}
+		return "", false
+	case "undo":
+		if len(s.history) == 0 {
+			return "Nothing to undo", false
+		}
+		st := s.history[len(s.history)-1]
+		s.ks, s.fixed = st.ks, st.fixed
+		s.history = s.history[:len(s.history)-1]
+		return "", false
+	default:
+		return fmt.Sprintf("Unknown command %q (try \"help\")", cmd), false
+	}
+}
+
+// save pushes the current keystream and fixed columns onto the undo stack.
+func (s *session) save() {
+	s.history = append(s.history, state{
+		ks:    append([]byte{}, s.ks...),
+		fixed: append([]bool{}, s.fixed...),
+	})
+}
+
+// setKeystream saves the current keystream and then copies ks into it at col.
+func (s *session) setKeystream(col int, ks []byte) {
+	s.save()
+	copy(s.ks[col:], ks)
+	for i := col; i < col+len(ks) && i < len(s.fixed); i++ {
+		s.fixed[i] = true
+	}
+}
+
+// placementList returns a description of s.placements.
+func (s *session) placementList(crib []byte) string {
+	if len(s.placements) == 0 {
+		return fmt.Sprintf("No placements for %q", crib)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Best placements for %q:\n", crib)
+	for i, p := range s.placements {
+		fmt.Fprintf(&b, "%3d: row %3d col %3d score %7.3f\n", i, p.Row, p.Pos, p.Score)
+	}
+	b.WriteString(`Type "use N" to apply a placement.`)
+	return b.String()
+}
+
+// render writes the current decryptions to w, followed by msg.
+func (s *session) render(w io.Writer, msg string) {
+	if s.clear {
+		fmt.Fprint(w, "\x1b[H\x1b[2J")
+	}
+
+	// Draw a ruler with a tick every ten columns and marks under fixed columns.
+	var ruler, marks strings.Builder
+	for i := range s.ks {
+		switch {
+		case i%10 == 0:
+			ruler.WriteString(strconv.Itoa(i / 10 % 10))
+		default:
+			ruler.WriteByte(' ')
+		}
+		if s.fixed[i] {
+			marks.WriteByte('*')
+		} else {
+			marks.WriteByte(' ')
+		}
+	}
+	fmt.Fprintf(w, "     %s\n", ruler.String())
+	for i, ct := range s.cts {
+		fmt.Fprintf(w, "%3d  %s\n", i, printable(common.XOR(ct, s.ks[:len(ct)])))
+	}
+	fmt.Fprintf(w, "     %s\n", strings.TrimRight(marks.String(), " "))
+	if msg != "" {
+		fmt.Fprintln(w, msg)
+	}
+}
+
+// printable returns b as a string with non-printable bytes replaced by '.'.
+func printable(b []byte) string {
+	p := make([]byte, len(b))
+	for i, c := range b {
+		if c >= ' ' && c <= '~' {
+			p[i] = c
+		} else {
+			p[i] = '.'
+		}
+	}
+	return string(p)
+}
+
+// parseText parses a command argument, which may be a Go-quoted string.
+func parseText(s string) ([]byte, error) {
+	if strings.HasPrefix(s, `"`) {
+		u, err := strconv.Unquote(s)
+		return []byte(u), err
+	}
+	return []byte(s), nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/derat/cryptopals/common"
+)
+
+func runAESECBDetect(fs *flag.FlagSet, args []string, w io.Writer) error {
+	iof := addIOFlags(fs, encHex)
+	bs := fs.Int("bs", 16, "Cipher block size in bytes")
+	all := fs.Bool("all", false, "Print all lines rather than only ones that appear to use ECB")
+	if err := parseFlags(fs, args, iof); err != nil {
+		return err
+	}
+	if *bs <= 0 {
+		return fmt.Errorf("bad block size %v", *bs)
+	}
+
+	cts, err := iof.readLines()
+	if err != nil {
+		return err
+	}
+	var recs []record
+	for i, g := range common.ClassifyCiphertexts(cts, *bs) {
+		if g.Mode != common.ModeECB && !*all {
+			continue
+		}
+		recs = append(recs, record{
+			{"line", i + 1},
+			{"mode", g.Mode.String()},
+			{"confidence", g.Confidence},
+			{"ciphertext", cts[i]},
+		})
+	}
+	return iof.write(w, recs)
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/derat/cryptopals/challenges"
+	_ "github.com/derat/cryptopals/challenges/all"
+)
+
+func runChallengeList(fs *flag.FlagSet, args []string, w io.Writer) error {
+	if err := parseFlags(fs, args, nil); err != nil {
+		return err
+	}
+	for _, c := range challenges.All() {
+		fmt.Fprintf(w, "%2d  %s\n", c.Num, c.Title)
+	}
+	return nil
+}
+
+func runChallengeRun(fs *flag.FlagSet, args []string, w io.Writer) error {
+	var opts challenges.Options
+	fs.BoolVar(&opts.Fast, "fast", false, "Do less work in slow challenges (e.g. timing attacks)")
+	fs.Int64Var(&opts.Seed, "seed", 0, "Seed for deterministic keys and inputs (0 for secure randomness)")
+	quiet := fs.Bool("quiet", false, "Only print whether each challenge passed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Run all challenges if none were specified.
+	var cs []challenges.Challenge
+	for _, a := range fs.Args() {
+		n, err := strconv.Atoi(a)
+		if err != nil {
+			return fmt.Errorf("bad challenge number %q", a)
+		}
+		c, ok := challenges.Get(n)
+		if !ok {
+			return fmt.Errorf("no challenge %d", n)
+		}
+		cs = append(cs, c)
+	}
+	if len(cs) == 0 {
+		cs = challenges.All()
+	}
+
+	var failed int
+	for _, c := range cs {
+		fmt.Fprintf(w, "=== Challenge %d: %s\n", c.Num, c.Title)
+		out := w
+		if *quiet {
+			out = io.Discard
+		}
+		d, err := challenges.Run(context.Background(), c, opts, out)
+		if err != nil {
+			fmt.Fprintf(w, "--- FAIL (%v): %v\n", d.Round(time.Millisecond), err)
+			failed++
+		} else {
+			fmt.Fprintf(w, "--- PASS (%v)\n", d.Round(time.Millisecond))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d challenge(s) failed", failed, len(cs))
+	}
+	return nil
+}
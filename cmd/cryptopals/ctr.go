@@ -0,0 +1,30 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io"
+
+	"github.com/derat/cryptopals/common"
+)
+
+func runCTRFixedNonce(fs *flag.FlagSet, args []string, w io.Writer) error {
+	iof := addIOFlags(fs, encBase64)
+	if err := parseFlags(fs, args, iof); err != nil {
+		return err
+	}
+
+	cts, err := iof.readLines()
+	if err != nil {
+		return err
+	}
+	ks, plains := common.BreakFixedNonceCTR(cts)
+	recs := []record{{{"keystream", ks}}}
+	for i, p := range plains {
+		recs = append(recs, record{{"line", i + 1}, {"plain", p}})
+	}
+	return iof.write(w, recs)
+}
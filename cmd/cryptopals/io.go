@@ -0,0 +1,182 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Supported values for the -enc and -out flags.
+const (
+	encHex    = "hex"
+	encBase64 = "base64"
+	encRaw    = "raw"
+
+	outText   = "text"
+	outHex    = "hex"
+	outBase64 = "base64"
+	outJSON   = "json"
+)
+
+// ioFlags holds flags describing a command's input and output.
+type ioFlags struct {
+	in  string // input file, or "-" for stdin
+	enc string // input encoding
+	out string // output format
+}
+
+// addIOFlags adds -in, -enc, and -out flags to fs. defEnc is the default input encoding.
+// If defEnc is empty, the -enc flag is omitted and input is always read as text.
+func addIOFlags(fs *flag.FlagSet, defEnc string) *ioFlags {
+	f := ioFlags{enc: encRaw}
+	fs.StringVar(&f.in, "in", "-", `Input file ("-" for stdin)`)
+	if defEnc != "" {
+		fs.StringVar(&f.enc, "enc", defEnc, `Input encoding ("hex", "base64", or "raw")`)
+	}
+	fs.StringVar(&f.out, "out", outText,
+		`Output format ("text" quotes binary data; "hex" or "base64" encode it; "json" uses base64)`)
+	return &f
+}
+
+// check returns an error if the flags contain unsupported values.
+func (f *ioFlags) check() error {
+	switch f.enc {
+	case encHex, encBase64, encRaw:
+	default:
+		return fmt.Errorf("bad input encoding %q", f.enc)
+	}
+	switch f.out {
+	case outText, outHex, outBase64, outJSON:
+	default:
+		return fmt.Errorf("bad output format %q", f.out)
+	}
+	return nil
+}
+
+// readAll returns the full contents of the input file without decoding it.
+func (f *ioFlags) readAll() ([]byte, error) {
+	if f.in == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(f.in)
+}
+
+// read returns the decoded contents of the input file. Whitespace (including newlines)
+// is ignored in hex- and base64-encoded input.
+func (f *ioFlags) read() ([]byte, error) {
+	b, err := f.readAll()
+	if err != nil {
+		return nil, err
+	}
+	if f.enc == encRaw {
+		return b, nil
+	}
+	return decode(strings.Join(strings.Fields(string(b)), ""), f.enc)
+}
+
+// readLines returns the decoded non-empty lines of the input file.
+func (f *ioFlags) readLines() ([][]byte, error) {
+	b, err := f.readAll()
+	if err != nil {
+		return nil, err
+	}
+	var lines [][]byte
+	for i, ln := range bytes.Split(b, []byte("\n")) {
+		if f.enc != encRaw {
+			ln = bytes.TrimSpace(ln)
+		} else {
+			ln = bytes.TrimSuffix(ln, []byte("\r"))
+		}
+		if len(ln) == 0 {
+			continue
+		}
+		if f.enc != encRaw {
+			if ln, err = decode(string(ln), f.enc); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+		lines = append(lines, ln)
+	}
+	return lines, nil
+}
+
+// decode decodes s using enc, which must be encHex or encBase64.
+func decode(s, enc string) ([]byte, error) {
+	switch enc {
+	case encHex:
+		return hex.DecodeString(s)
+	case encBase64:
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("can't decode %q", enc)
+	}
+}
+
+// field is a named value within a record.
+type field struct {
+	name string
+	val  interface{}
+}
+
+// record is a single result written by a command.
+type record []field
+
+// write writes recs to w in the format specified by f.out.
+func (f *ioFlags) write(w io.Writer, recs []record) error {
+	if f.out == outJSON {
+		objs := make([]map[string]interface{}, len(recs))
+		for i, r := range recs {
+			objs[i] = make(map[string]interface{}, len(r))
+			for _, fl := range r {
+				objs[i][fl.name] = fl.val
+			}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(objs)
+	}
+
+	for i, r := range recs {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		for _, fl := range r {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", fl.name, f.format(fl.val)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// format formats v for text output.
+func (f *ioFlags) format(v interface{}) string {
+	switch tv := v.(type) {
+	case []byte:
+		switch f.out {
+		case outHex:
+			return hex.EncodeToString(tv)
+		case outBase64:
+			return base64.StdEncoding.EncodeToString(tv)
+		default:
+			return fmt.Sprintf("%q", tv)
+		}
+	case float64:
+		return fmt.Sprintf("%.4f", tv)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Cryptopals applies the techniques from the challenges to arbitrary data.
+//
+// Usage:
+//
+//	cryptopals <group> <command> [flags]
+//
+// Run "cryptopals help" for a list of commands, or pass -h to a command
+// for a description of its flags. Most commands read their input from the
+// file passed via -in (or stdin) in the encoding given by -enc, and write
+// their results in the format given by -out.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command describes a subcommand.
+type command struct {
+	group, name string
+	desc        string
+	run         func(fs *flag.FlagSet, args []string, w io.Writer) error
+	args        string // positional arguments for usage message, if any
+}
+
+var commands = []command{
+	{"xor", "break", "break repeating-key XOR", runXORBreak, ""},
+	{"aes", "ecb-detect", "find ciphertexts that appear to use ECB mode", runAESECBDetect, ""},
+	{"ctr", "fixed-nonce", "decrypt stream ciphertexts that reuse the same keystream", runCTRFixedNonce, ""},
+	{"mt", "clone", "clone an MT19937 PRNG from its outputs and predict its next outputs", runMTClone, ""},
+	{"sha1", "extend", "forge SHA-1 secret-prefix MACs using length extension", runSHA1Extend, ""},
+	{"challenge", "list", "list the challenges", runChallengeList, ""},
+	{"challenge", "run", "run challenges (all if no numbers are given)", runChallengeRun, " [num]..."},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <group> <command> [flag]...\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-18s %s\n", c.group+" "+c.name, c.desc)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "-help" {
+		usage(os.Stdout)
+		return
+	}
+	if len(os.Args) < 3 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	group, name := os.Args[1], os.Args[2]
+	for _, c := range commands {
+		if c.group != group || c.name != name {
+			continue
+		}
+		fs := flag.NewFlagSet(group+" "+name, flag.ExitOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: %s %s %s [flag]...%s\n\n", os.Args[0], group, name, c.args)
+			fs.PrintDefaults()
+		}
+		if err := c.run(fs, os.Args[3:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", group, name, err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", group+" "+name)
+	usage(os.Stderr)
+	os.Exit(2)
+}
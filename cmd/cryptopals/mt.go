@@ -0,0 +1,57 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/derat/cryptopals/common"
+)
+
+func runMTClone(fs *flag.FlagSet, args []string, w io.Writer) error {
+	iof := addIOFlags(fs, "")
+	predict := fs.Int("predict", 10, "Number of subsequent outputs to predict")
+	mt64 := fs.Bool("64", false, "Clone MT19937-64 instead of 32-bit MT19937")
+	if err := parseFlags(fs, args, iof); err != nil {
+		return err
+	}
+	if *predict < 0 {
+		return fmt.Errorf("bad prediction count %v", *predict)
+	}
+
+	// The input should contain consecutive outputs as whitespace-separated numbers.
+	// Any outputs beyond the number needed to clone the generator are used to check the clone.
+	b, err := iof.readAll()
+	if err != nil {
+		return err
+	}
+	var vals []uint64
+	for _, s := range strings.Fields(string(b)) {
+		v, err := strconv.ParseUint(s, 0, 64)
+		if err != nil {
+			return err
+		}
+		vals = append(vals, v)
+	}
+
+	params := common.MT19937Params
+	if *mt64 {
+		params = common.MT19937_64Params
+	}
+	mt, err := common.CloneMT(params, vals)
+	if err != nil {
+		return err
+	}
+
+	recs := make([]record, *predict)
+	for i := range recs {
+		recs[i] = record{{"index", len(vals) + i}, {"value", mt.Extract()}}
+	}
+	return iof.write(w, recs)
+}
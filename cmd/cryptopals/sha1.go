@@ -0,0 +1,63 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/derat/cryptopals/common"
+	"github.com/derat/cryptopals/sha1"
+)
+
+func runSHA1Extend(fs *flag.FlagSet, args []string, w io.Writer) error {
+	iof := addIOFlags(fs, encRaw)
+	macHex := fs.String("mac", "", "Hex-encoded MAC of the original message (required)")
+	suffix := fs.String("suffix", "", "Data to append to the original message")
+	suffixHex := fs.String("suffix-hex", "", "Hex-encoded data to append (instead of -suffix)")
+	minKey := fs.Int("min-key", 0, "Minimum secret key length to try")
+	maxKey := fs.Int("max-key", 32, "Maximum secret key length to try")
+	if err := parseFlags(fs, args, iof); err != nil {
+		return err
+	}
+
+	omac, err := hex.DecodeString(*macHex)
+	if err != nil {
+		return fmt.Errorf("bad -mac: %v", err)
+	} else if len(omac) != sha1.Size {
+		return fmt.Errorf("-mac must be %v bytes; got %v", sha1.Size, len(omac))
+	}
+	extra := []byte(*suffix)
+	if *suffixHex != "" {
+		if *suffix != "" {
+			return errors.New("-suffix and -suffix-hex are mutually exclusive")
+		}
+		if extra, err = hex.DecodeString(*suffixHex); err != nil {
+			return fmt.Errorf("bad -suffix-hex: %v", err)
+		}
+	}
+	if *minKey < 0 || *maxKey < *minKey {
+		return fmt.Errorf("bad key length range [%v, %v]", *minKey, *maxKey)
+	}
+	omsg, err := iof.read()
+	if err != nil {
+		return err
+	}
+
+	// The key length is unknown, so produce a forgery for each possible length.
+	var recs []record
+	for kl := *minKey; kl <= *maxKey; kl++ {
+		mac, pad, err := common.LengthExtend(common.SHA1, omac, kl+len(omsg), extra)
+		if err != nil {
+			return err
+		}
+		msg := append(append(append([]byte{}, omsg...), pad...), extra...)
+		recs = append(recs, record{{"key_len", kl}, {"mac", mac}, {"message", msg}})
+	}
+	return iof.write(w, recs)
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/derat/cryptopals/common"
+)
+
+func runXORBreak(fs *flag.FlagSet, args []string, w io.Writer) error {
+	iof := addIOFlags(fs, encBase64)
+	var opts common.XOROptions
+	fs.IntVar(&opts.MinKeySize, "min", 2, "Minimum key size to consider")
+	fs.IntVar(&opts.MaxKeySize, "max", 40, "Maximum key size to consider")
+	fs.IntVar(&opts.Blocks, "blocks", 4, "Number of blocks to compare when ranking key sizes")
+	fs.IntVar(&opts.KeySizes, "sizes", 5, "Number of top-ranked key sizes to try")
+	corpus := fs.String("corpus", "", "Text file for training a trigram model to score plaintexts")
+	num := fs.Int("n", 1, "Number of candidates to print")
+	if err := parseFlags(fs, args, iof); err != nil {
+		return err
+	}
+	if *num < 1 {
+		return fmt.Errorf("bad candidate count %v", *num)
+	}
+
+	if *corpus != "" {
+		m, err := common.LoadNGram(*corpus, 3)
+		if err != nil {
+			return err
+		}
+		opts.Scorer = m
+	}
+	enc, err := iof.read()
+	if err != nil {
+		return err
+	}
+	cands, err := common.BreakRepeatingXOR(enc, &opts)
+	if err != nil {
+		return err
+	}
+	if len(cands) > *num {
+		cands = cands[:*num]
+	}
+	recs := make([]record, len(cands))
+	for i, c := range cands {
+		recs[i] = record{
+			{"key", c.Key},
+			{"score", c.Score},
+			{"dist", c.Dist},
+			{"plain", c.Plain},
+		}
+	}
+	return iof.write(w, recs)
+}
+
+// parseFlags parses args into fs and checks iof (which may be nil).
+// Positional arguments are not accepted.
+func parseFlags(fs *flag.FlagSet, args []string, iof *ioFlags) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if iof != nil {
+		return iof.check()
+	}
+	return nil
+}
@@ -7,9 +7,10 @@ package common
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
 	"encoding/binary"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -30,20 +31,61 @@ func PadPKCS7(b []byte, bs int) []byte {
 // UnpadPKCS7 undoes padding added by PadPKCS7.
 func UnpadPKCS7(b []byte) ([]byte, error) {
 	if len(b) == 0 {
-		return nil, errors.New("can't unpad empty buffer")
+		return nil, fmt.Errorf("%w: can't unpad empty buffer", ErrBadPadding)
 	}
 	np := int(b[len(b)-1])
 	if np == 0 || np > len(b) {
-		return nil, fmt.Errorf("%v byte(s) of padding on %v-byte buffer", np, len(b))
+		return nil, fmt.Errorf("%w: %v byte(s) of padding on %v-byte buffer", ErrBadPadding, np, len(b))
 	}
 	for i := 0; i < np; i++ {
 		if idx := len(b) - i - 1; int(b[idx]) != np {
-			return nil, fmt.Errorf("%v byte(s) of padding but byte %d is %v", np, idx, b[idx])
+			return nil, fmt.Errorf("%w: %v byte(s) of padding but byte %d is %v", ErrBadPadding, np, idx, b[idx])
 		}
 	}
 	return b[:len(b)-np], nil
 }
 
+// UnpadPKCS7ConstantTime undoes padding added by PadPKCS7 with block size bs.
+// Unlike UnpadPKCS7, it examines every byte of the final block regardless of where
+// the padding appears to start and returns ErrBadPadding for all invalid paddings,
+// so neither its timing nor its error reveals which byte was wrong.
+// The length of b (which isn't secret) must be a non-zero multiple of bs.
+func UnpadPKCS7ConstantTime(b []byte, bs int) ([]byte, error) {
+	if bs <= 0 || bs > 255 || len(b) == 0 || len(b)%bs != 0 {
+		return nil, fmt.Errorf("%w: %v-byte buffer with block size %v", ErrPartialBlock, len(b), bs)
+	}
+	np, ok := checkPKCS7(b[len(b)-bs:])
+	if ok != 1 {
+		return nil, ErrBadPadding
+	}
+	return b[:len(b)-np], nil
+}
+
+// ValidPKCS7 returns true if b ends with valid PKCS#7 padding for block size bs.
+// Like UnpadPKCS7ConstantTime, it runs in time that depends only on bs.
+func ValidPKCS7(b []byte, bs int) bool {
+	if bs <= 0 || bs > 255 || len(b) == 0 || len(b)%bs != 0 {
+		return false
+	}
+	_, ok := checkPKCS7(b[len(b)-bs:])
+	return ok == 1
+}
+
+// checkPKCS7 examines last, a full final block, in constant time.
+// It returns the claimed number of padding bytes and 1 if the padding is valid or 0 otherwise.
+func checkPKCS7(last []byte) (np, ok int) {
+	bs := len(last)
+	np = int(last[bs-1])
+	ok = subtle.ConstantTimeLessOrEq(1, np) & subtle.ConstantTimeLessOrEq(np, bs)
+	for i := 0; i < bs; i++ {
+		// Bytes within the padding (i.e. the final np bytes) must equal np.
+		inPad := subtle.ConstantTimeLessOrEq(bs, i+np)
+		match := subtle.ConstantTimeByteEq(last[i], byte(np))
+		ok &= subtle.ConstantTimeSelect(inPad, match, 1)
+	}
+	return np, ok
+}
+
 // A returns a buffer containing the byte 'A' repeated n times.
 func A(n int) []byte {
 	return bytes.Repeat([]byte{'A'}, n)
@@ -69,54 +111,127 @@ func BlockString(b []byte, bs int) string {
 
 // FirstModBlock returns the index of the first modifiable block for f,
 // an ECB or CBC function with a fixed key and fixed prefix.
+// It panics on failure; see TryFirstModBlock.
 func FirstModBlock(f EncryptFunc, bs int) int {
+	i, err := TryFirstModBlock(f, bs)
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+// TryFirstModBlock is like FirstModBlock but returns ErrNoModBlock on failure.
+func TryFirstModBlock(f EncryptFunc, bs int) (int, error) {
 	a := f(A(bs))
 	b := f(B(bs))
-	for i := 0; i*bs < len(a); i++ {
+	for i := 0; (i+1)*bs <= len(a) && (i+1)*bs <= len(b); i++ {
 		// The first block that differs is the first one that we can modify.
 		if !bytes.Equal(a[i*bs:(i+1)*bs], b[i*bs:(i+1)*bs]) {
-			return i
+			return i, nil
 		}
 	}
-	panic("couldn't find modifiable block")
+	return 0, ErrNoModBlock
 }
 
 // FixedLen returns the combined length of a fixed prefix and suffix used by f,
 // an ECB or CBC function with a fixed key.
+// It panics on failure; see TryFixedLen.
 func FixedLen(f EncryptFunc, bs int) int {
-	base := len(f(nil))
-	for i := 1; ; i++ {
-		if n := len(f(A(i))); n > base {
-			return base - i
+	n, err := TryFixedLen(f, bs)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// TryFixedLen is like FixedLen but returns ErrFixedLenNotFound if the ciphertext
+// doesn't grow after adding bs bytes of input.
+func TryFixedLen(f EncryptFunc, bs int) (int, error) {
+	return fixedLen(tryEncrypt(f), bs)
+}
+
+// fixedLen implements TryFixedLen. Errors returned by f are returned as-is.
+func fixedLen(f tryEncryptFunc, bs int) (int, error) {
+	enc, err := f(nil)
+	if err != nil {
+		return 0, err
+	}
+	base := len(enc)
+	for i := 1; i <= bs; i++ {
+		if enc, err = f(A(i)); err != nil {
+			return 0, err
+		} else if len(enc) > base {
+			return base - i, nil
 		}
 	}
+	return 0, ErrFixedLenNotFound
 }
 
 // PrefixLen returns the length of the fixed prefix used by f,
 // an ECB or CBC function with a fixed key.
+// It panics on failure; see TryPrefixLen.
 func PrefixLen(f EncryptFunc, bs int) int {
-	start := FirstModBlock(f, bs) * bs
+	n, err := TryPrefixLen(f, bs)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// TryPrefixLen is like PrefixLen but returns an error wrapping ErrNoModBlock or
+// ErrPrefixNotFound on failure.
+func TryPrefixLen(f EncryptFunc, bs int) (int, error) {
+	mb, err := TryFirstModBlock(f, bs)
+	if err != nil {
+		return 0, err
+	}
+	start := mb * bs
 	end := start + bs
 
+	// block returns the modifiable block from f's output for b, or nil if it's too short.
+	block := func(b []byte) []byte {
+		if enc := f(b); len(enc) >= end {
+			return enc[start:end]
+		}
+		return nil
+	}
+
 	// Figure out what the modifiable block looks like when its remaining bytes
 	// are filled with our own characters.
-	a := f(A(bs))[start:end]
-	b := f(B(bs))[start:end]
+	a := block(A(bs))
+	b := block(B(bs))
 
 	// Add bytes until we see the expected blocks.
 	for i := 0; i < bs; i++ {
-		if bytes.Equal(f(A(i + 1))[start:end], a) &&
-			bytes.Equal(f(B(i + 1))[start:end], b) {
-			return end - i - 1
+		if bytes.Equal(block(A(i+1)), a) && bytes.Equal(block(B(i+1)), b) {
+			return end - i - 1, nil
 		}
 	}
-	panic("couldn't find prefix length")
+	return 0, ErrPrefixNotFound
 }
 
 // SuffixLen returns the length of the fixed suffix used by f,
 // an ECB or CBC function with a fixed key.
+// It panics on failure; see TrySuffixLen.
 func SuffixLen(f EncryptFunc, bs int) int {
-	return FixedLen(f, bs) - PrefixLen(f, bs)
+	n, err := TrySuffixLen(f, bs)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// TrySuffixLen is like SuffixLen but returns an error on failure.
+func TrySuffixLen(f EncryptFunc, bs int) (int, error) {
+	fl, err := TryFixedLen(f, bs)
+	if err != nil {
+		return 0, err
+	}
+	pl, err := TryPrefixLen(f, bs)
+	if err != nil {
+		return 0, err
+	}
+	return fl - pl, nil
 }
 
 // EncryptFunc encrypts the supplied buffer.
@@ -124,115 +239,256 @@ func SuffixLen(f EncryptFunc, bs int) int {
 // The same prefix, suffix, and key are used every time.
 type EncryptFunc func(b []byte) []byte
 
+// tryEncryptFunc is like EncryptFunc but can return an error, e.g. if it wraps another
+// function and is unable to interpret its output.
+type tryEncryptFunc func(b []byte) ([]byte, error)
+
+// tryEncrypt returns a tryEncryptFunc that calls f and never returns an error.
+func tryEncrypt(f EncryptFunc) tryEncryptFunc {
+	return func(b []byte) ([]byte, error) { return f(b), nil }
+}
+
 // EncryptAES encrypts b using AES-128 with the supplied key.
 // If iv is non-nil CBC mode is used; otherwise ECB is used.
+// It panics on failure; see TryEncryptAES.
 func EncryptAES(b, key, iv []byte) []byte {
-	cipher, err := aes.NewCipher(key)
+	enc, err := TryEncryptAES(b, key, iv)
 	if err != nil {
 		panic(err)
 	}
-	bs := cipher.BlockSize()
-
-	if iv != nil && len(iv) != bs {
-		panic(fmt.Sprintf("IV size is %v; need %v", len(iv), bs))
-	}
-	prev := iv
+	return enc
+}
 
-	if len(b)%bs != 0 {
-		panic(fmt.Sprintf("buffer size %v isn't multiple of block size %v", len(b), bs))
+// TryEncryptAES is like EncryptAES but returns an error if key or iv is invalid
+// or if b isn't a multiple of the block size.
+func TryEncryptAES(b, key, iv []byte) ([]byte, error) {
+	m, err := newAESMode(key, iv, true)
+	if err != nil {
+		return nil, err
 	}
-
-	var enc []byte
-	for i := 0; i < len(b); i += bs {
-		// Get the source block, padding it if needed.
-		n := bs
-		if rem := len(b) - i; rem < bs {
-			n = rem
-		}
-		src := b[i : i+n]
-
-		// If using CBC, XOR with the previous ciphertext block (or the initialization vector).
-		if iv != nil {
-			src = XOR(src, prev)
-		}
-
-		// Encrypt the block and save it to XOR against the next plaintext block (for CBC).
-		dst := make([]byte, bs)
-		cipher.Encrypt(dst, src)
-		enc = append(enc, dst...)
-		prev = dst
+	enc := make([]byte, len(b))
+	if err := CryptBlocks(m, enc, b); err != nil {
+		return nil, err
 	}
-	return enc
+	return enc, nil
 }
 
 // DecryptAES decrypts b using AES-128 with the supplied key.
 // If iv is non-nil CBC mode is used; otherwise ECB is used.
+// A trailing partial block is zero-filled before being decrypted, and the
+// decrypted data is truncated to len(enc).
+// It panics if key or iv is invalid; see TryDecryptAES.
 func DecryptAES(enc, key, iv []byte) []byte {
-	cipher, err := aes.NewCipher(key)
+	m, err := newAESMode(key, iv, false)
 	if err != nil {
 		panic(err)
 	}
-	bs := cipher.BlockSize()
-
-	if iv != nil && len(iv) != bs {
-		panic(fmt.Sprintf("IV size is %v; need %v", len(iv), bs))
+	src := enc
+	if rem := len(enc) % m.BlockSize(); rem != 0 {
+		src = make([]byte, len(enc)+m.BlockSize()-rem)
+		copy(src, enc)
 	}
-	prev := iv
+	dec := make([]byte, len(src))
+	m.CryptBlocks(dec, src)
+	return dec[:len(enc)]
+}
 
-	dec := make([]byte, 0, len(enc))
-	for i := 0; i < len(enc); i += bs {
-		src := make([]byte, bs)
-		dst := make([]byte, bs)
-		n := copy(src, enc[i:])
-		cipher.Decrypt(dst, src)
+// TryDecryptAES is like DecryptAES but returns an error if key or iv is invalid
+// or if enc isn't a multiple of the block size.
+func TryDecryptAES(enc, key, iv []byte) ([]byte, error) {
+	m, err := newAESMode(key, iv, false)
+	if err != nil {
+		return nil, err
+	}
+	dec := make([]byte, len(enc))
+	if err := CryptBlocks(m, dec, enc); err != nil {
+		return nil, err
+	}
+	return dec, nil
+}
 
-		if iv != nil {
-			dst = XOR(dst, prev)
+// newAESMode returns a cipher.BlockMode for AES-128 with the supplied key.
+// If iv is non-nil CBC mode is used; otherwise ECB is used.
+func newAESMode(key, iv []byte, encrypt bool) (cipher.BlockMode, error) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if iv == nil {
+		if encrypt {
+			return NewECBEncrypter(b), nil
 		}
-
-		dec = append(dec, dst[:n]...)
-		prev = src
+		return NewECBDecrypter(b), nil
+	}
+	if encrypt {
+		return NewCBCEncrypter(b, iv)
 	}
-	return dec
+	return NewCBCDecrypter(b, iv)
 }
 
-// CTR implements AES in CTR mode.
+// CTRLayout describes how the nonce and block counter are arranged within each
+// counter block that is encrypted to produce the keystream.
+type CTRLayout int
+
+const (
+	// CTRLittleEndian places a little-endian nonce in the first half of the counter block
+	// and a little-endian block counter in the second half. This is the layout used by
+	// the challenges.
+	CTRLittleEndian CTRLayout = iota
+	// CTRBigEndian is the same as CTRLittleEndian, but the nonce and block counter
+	// are both big-endian.
+	CTRBigEndian
+	// CTRNIST treats the entire counter block as a big-endian integer that is initialized
+	// to the IV and incremented for each block, as described in NIST SP 800-38A and
+	// implemented by crypto/cipher.NewCTR.
+	CTRNIST
+)
+
+// CTR implements CTR mode for an arbitrary block cipher.
+// It implements cipher.Stream.
 type CTR struct {
-	key           []byte
-	nonce, blocks uint64
-	ks            []byte // next portion of keystream
+	b      cipher.Block
+	bs     int
+	iv     []byte // nonce, or initial counter block for CTRNIST
+	layout CTRLayout
+	blocks uint64 // index of next counter block to encrypt
+	cb     []byte // counter block
+	ks     []byte // keystream block
+	ksOff  int    // offset of next unused byte in ks
 }
 
+// NewCTR returns a CTR using AES with the supplied key and the CTRLittleEndian layout.
 func NewCTR(key []byte, nonce uint64) *CTR {
-	return &CTR{key: key, nonce: nonce}
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	iv := make([]byte, 8)
+	binary.LittleEndian.PutUint64(iv, nonce)
+	c, err := NewCTRLayout(b, iv, CTRLittleEndian)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// NewCTRLayout returns a CTR using b with the supplied counter block layout.
+// For CTRLittleEndian and CTRBigEndian, iv is the nonce and must be half of b's block size.
+// For CTRNIST, iv is the initial counter block and must be b's block size.
+func NewCTRLayout(b cipher.Block, iv []byte, layout CTRLayout) (*CTR, error) {
+	bs := b.BlockSize()
+	switch layout {
+	case CTRLittleEndian, CTRBigEndian:
+		if bs%2 != 0 || len(iv) != bs/2 {
+			return nil, fmt.Errorf("%w: nonce size is %v; need %v", ErrBadIVSize, len(iv), bs/2)
+		}
+	case CTRNIST:
+		if len(iv) != bs {
+			return nil, fmt.Errorf("%w: got %v; need %v", ErrBadIVSize, len(iv), bs)
+		}
+	default:
+		return nil, fmt.Errorf("invalid layout %v", layout)
+	}
+	return &CTR{
+		b:      b,
+		bs:     bs,
+		iv:     append([]byte{}, iv...),
+		layout: layout,
+		cb:     make([]byte, bs),
+		ks:     make([]byte, bs),
+		ksOff:  bs,
+	}, nil
 }
 
 // Reset resets c's block counter to 0.
 func (c *CTR) Reset() {
 	c.blocks = 0
-	c.ks = nil
+	c.ksOff = c.bs
 }
 
-// keystream returns the next n bytes from the keystream.
-func (c *CTR) keystream(n int) []byte {
-	b := make([]byte, n)
-	off := 0
+// Seek moves c to the supplied byte offset in the keystream, implementing io.Seeker.
+// whence must be io.SeekStart or io.SeekCurrent, since the keystream has no end.
+func (c *CTR) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.pos()
+	default:
+		return 0, fmt.Errorf("unsupported whence %v", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %v", offset)
+	}
+	c.blocks = uint64(offset / int64(c.bs))
+	c.ksOff = c.bs
+	if rem := int(offset % int64(c.bs)); rem > 0 {
+		c.nextBlock()
+		c.ksOff = rem
+	}
+	return offset, nil
+}
 
-	for off < n {
-		if len(c.ks) > 0 {
-			copied := copy(b[off:], c.ks)
-			off += copied
-			c.ks = c.ks[copied:]
-		} else {
-			var buf bytes.Buffer
-			buf.Grow(16)
-			binary.Write(&buf, binary.LittleEndian, &c.nonce)
-			binary.Write(&buf, binary.LittleEndian, &c.blocks)
-			c.ks = EncryptAES(buf.Bytes(), c.key, nil)
-			c.blocks++
+// pos returns c's current byte offset in the keystream.
+func (c *CTR) pos() int64 {
+	if c.ksOff == c.bs {
+		return int64(c.blocks) * int64(c.bs)
+	}
+	return int64(c.blocks-1)*int64(c.bs) + int64(c.ksOff)
+}
+
+// nextBlock encrypts the next counter block into c.ks.
+func (c *CTR) nextBlock() {
+	half := c.bs / 2
+	switch c.layout {
+	case CTRLittleEndian:
+		copy(c.cb, c.iv)
+		for i := half; i < c.bs; i++ {
+			c.cb[i] = byte(c.blocks >> (8 * uint(i-half)))
+		}
+	case CTRBigEndian:
+		copy(c.cb, c.iv)
+		for i := c.bs - 1; i >= half; i-- {
+			c.cb[i] = byte(c.blocks >> (8 * uint(c.bs-1-i)))
+		}
+	case CTRNIST:
+		// Add the block counter to the IV, treating both as big-endian integers.
+		var carry uint64
+		for i := c.bs - 1; i >= 0; i-- {
+			var add uint64
+			if shift := 8 * uint(c.bs-1-i); shift < 64 {
+				add = (c.blocks >> shift) & 0xff
+			}
+			sum := uint64(c.iv[i]) + add + carry
+			c.cb[i] = byte(sum)
+			carry = sum >> 8
+		}
+	}
+	c.b.Encrypt(c.ks, c.cb)
+	c.ksOff = 0
+	c.blocks++
+}
+
+// XORKeyStream XORs each byte in src with the next byte from the keystream
+// and writes the result to dst. dst and src may overlap entirely.
+func (c *CTR) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic(fmt.Sprintf("output size %v is smaller than input size %v", len(dst), len(src)))
+	}
+	for len(src) > 0 {
+		if c.ksOff == c.bs {
+			c.nextBlock()
+		}
+		n := c.bs - c.ksOff
+		if n > len(src) {
+			n = len(src)
+		}
+		for i := 0; i < n; i++ {
+			dst[i] = src[i] ^ c.ks[c.ksOff+i]
 		}
+		c.ksOff += n
+		src = src[n:]
+		dst = dst[n:]
 	}
-	return b
 }
 
 // Process reads from r until EOF and writes encrypted or unencrypted data to w.
@@ -241,8 +497,8 @@ func (c *CTR) Process(r io.Reader, w io.Writer) error {
 	for {
 		n, rerr := r.Read(b)
 		if n > 0 {
-			ks := c.keystream(n)
-			if _, err := w.Write(XOR(b[:n], ks)); err != nil {
+			c.XORKeyStream(b[:n], b[:n])
+			if _, err := w.Write(b[:n]); err != nil {
 				return err
 			}
 		}
@@ -6,10 +6,18 @@ package common
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"flag"
+	"io"
 	"strings"
 	"testing"
+	"time"
 )
 
+var checkTiming = flag.Bool("timing", false, "Fail timing tests if wall-clock measurements are out of range")
+
 func TestPKCS7(t *testing.T) {
 	for _, tc := range []struct {
 		unpadded string
@@ -31,6 +39,14 @@ func TestPKCS7(t *testing.T) {
 		} else if !bytes.Equal(unpadded, []byte(tc.unpadded)) {
 			t.Errorf("UnpadPKCS7(%q) = %q; want %q", tc.padded, unpadded, tc.unpadded)
 		}
+		if unpadded, err := UnpadPKCS7ConstantTime([]byte(tc.padded), tc.bs); err != nil {
+			t.Errorf("UnpadPKCS7ConstantTime(%q, %d) failed: %v", tc.padded, tc.bs, err)
+		} else if !bytes.Equal(unpadded, []byte(tc.unpadded)) {
+			t.Errorf("UnpadPKCS7ConstantTime(%q, %d) = %q; want %q", tc.padded, tc.bs, unpadded, tc.unpadded)
+		}
+		if !ValidPKCS7([]byte(tc.padded), tc.bs) {
+			t.Errorf("ValidPKCS7(%q, %d) = false; want true", tc.padded, tc.bs)
+		}
 	}
 }
 
@@ -48,6 +64,107 @@ func TestUnpadPKCS7_Invalid(t *testing.T) {
 	}
 }
 
+func TestUnpadPKCS7ConstantTime_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		padded string
+		bs     int
+		want   error
+	}{
+		{"1234567\x00", 8, ErrBadPadding},
+		{"123456\x03\x02", 8, ErrBadPadding},
+		{"1234567\x09", 8, ErrBadPadding},
+		{"\x01\x02", 2, ErrBadPadding},
+		{"ICE ICE BABY\x05\x05\x05\x05", 16, ErrBadPadding},
+		{"ICE ICE BABY\x01\x02\x03\x04", 16, ErrBadPadding},
+		{"", 8, ErrPartialBlock},
+		{"\x01\x01\x01", 2, ErrPartialBlock},
+	} {
+		if _, err := UnpadPKCS7ConstantTime([]byte(tc.padded), tc.bs); !errors.Is(err, tc.want) {
+			t.Errorf("UnpadPKCS7ConstantTime(%q, %d) returned %v; want %v", tc.padded, tc.bs, err, tc.want)
+		}
+		if ValidPKCS7([]byte(tc.padded), tc.bs) {
+			t.Errorf("ValidPKCS7(%q, %d) = true; want false", tc.padded, tc.bs)
+		}
+	}
+}
+
+// timingSpread measures unpad with each of the supplied buffers and returns the
+// difference between the slowest and fastest buffers divided by the fastest.
+// The buffers are measured in an interleaved fashion so that changes in machine load
+// affect all of them, and the fastest run for each buffer is used, since the minimum
+// is less affected by scheduling noise than the mean.
+func timingSpread(t *testing.T, unpad func(b []byte), bufs [][]byte) float64 {
+	const (
+		runs  = 200
+		iters = 500
+	)
+	mins := make([]time.Duration, len(bufs))
+	for i := 0; i < runs; i++ {
+		for j, b := range bufs {
+			start := time.Now()
+			for k := 0; k < iters; k++ {
+				unpad(b)
+			}
+			if d := time.Since(start); i == 0 || d < mins[j] {
+				mins[j] = d
+			}
+		}
+	}
+	min, max := mins[0], mins[0]
+	for i, d := range mins {
+		t.Logf("Buffer %d (%v): %.1f ns", i, BlockString(bufs[i][len(bufs[i])-16:], 16),
+			float64(d.Nanoseconds())/iters)
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return float64(max-min) / float64(min)
+}
+
+func TestUnpadPKCS7ConstantTime_Timing(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping timing test in short mode")
+	}
+
+	// Valid padding, plus invalid paddings that UnpadPKCS7 rejects after examining
+	// varying numbers of bytes.
+	const bs = 16
+	plain := PadPKCS7(bytes.Repeat([]byte{'x'}, 4*bs), bs)
+	var bufs [][]byte
+	for _, mod := range []struct{ idx, val int }{
+		{-1, -1}, // unmodified
+		{len(plain) - 1, 0},
+		{len(plain) - 1, bs + 1},
+		{len(plain) - 1, 200},
+		{len(plain) - 2, 0},
+		{len(plain) - bs, 0},
+	} {
+		b := append([]byte{}, plain...)
+		if mod.idx >= 0 {
+			b[len(b)-1] = bs
+			b[mod.idx] = byte(mod.val)
+		}
+		bufs = append(bufs, b)
+	}
+
+	// The constant-time version should take about the same time for all inputs.
+	// Wall-clock measurements are noisy on loaded machines, so the spread is only
+	// checked when -timing is passed.
+	const maxSpread = 0.5
+	spread := timingSpread(t, func(b []byte) { UnpadPKCS7ConstantTime(b, bs) }, bufs)
+	t.Logf("UnpadPKCS7ConstantTime spread: %.3f", spread)
+	if *checkTiming && spread > maxSpread {
+		t.Errorf("UnpadPKCS7ConstantTime timing spread is %.3f; want <= %.3f", spread, maxSpread)
+	}
+
+	// Log UnpadPKCS7's spread for comparison. It's typically much larger due to its early
+	// returns and the different errors that it constructs.
+	t.Logf("UnpadPKCS7 spread: %.3f", timingSpread(t, func(b []byte) { UnpadPKCS7(b) }, bufs))
+}
+
 func TestBlockString(t *testing.T) {
 	const bs = 4
 	for _, tc := range []struct {
@@ -158,6 +275,53 @@ func TestAES_CBC(t *testing.T) {
 	if !bytes.Equal(dec, padded) {
 		t.Fatalf("Decrypted %q; want %q", dec, padded)
 	}
+
+	// DecryptAES should decrypt a trailing partial block as if it were zero-filled.
+	part := append(append([]byte{}, enc...), 1, 2, 3)
+	full := append(append([]byte{}, part...), make([]byte, 13)...)
+	want := DecryptAES(full, []byte(key), []byte(iv))[:len(part)]
+	if got := DecryptAES(part, []byte(key), []byte(iv)); !bytes.Equal(got, want) {
+		t.Errorf("Decrypted partial block as %q; want %q", got, want)
+	}
+}
+
+func TestAES_Errors(t *testing.T) {
+	key := []byte("YELLOW SUBMARINE")
+	for _, tc := range []struct {
+		desc       string
+		b, key, iv []byte
+		want       error // sentinel error, or nil if any error is acceptable
+	}{
+		{"partial block", make([]byte, 17), key, nil, ErrPartialBlock},
+		{"bad IV size", make([]byte, 16), key, make([]byte, 8), ErrBadIVSize},
+		{"bad key size", make([]byte, 16), make([]byte, 5), nil, nil},
+	} {
+		for _, f := range []struct {
+			name string
+			fn   func(b, key, iv []byte) ([]byte, error)
+		}{
+			{"TryEncryptAES", TryEncryptAES},
+			{"TryDecryptAES", TryDecryptAES},
+		} {
+			if _, err := f.fn(tc.b, tc.key, tc.iv); err == nil {
+				t.Errorf("%v with %v unexpectedly succeeded", f.name, tc.desc)
+			} else if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Errorf("%v with %v returned %v; want %v", f.name, tc.desc, err, tc.want)
+			}
+		}
+	}
+}
+
+func TestTryPrefixLen_Errors(t *testing.T) {
+	const bs = 16
+	// A function that ignores its input doesn't have a modifiable block.
+	constant := func(b []byte) []byte { return make([]byte, 2*bs) }
+	if _, err := TryPrefixLen(constant, bs); !errors.Is(err, ErrNoModBlock) {
+		t.Errorf("TryPrefixLen with constant output returned %v; want %v", err, ErrNoModBlock)
+	}
+	if _, err := TryFixedLen(constant, bs); !errors.Is(err, ErrFixedLenNotFound) {
+		t.Errorf("TryFixedLen with constant output returned %v; want %v", err, ErrFixedLenNotFound)
+	}
 }
 
 func TestCTR(t *testing.T) {
@@ -226,3 +390,94 @@ func TestCTR_MultipleWrites(t *testing.T) {
 		t.Errorf("Decrypted to %q; want %q", dec.String(), full)
 	}
 }
+
+func TestCTR_NIST(t *testing.T) {
+	// Test vector from NIST SP 800-38A, F.5.1 (CTR-AES128.Encrypt).
+	var (
+		key   = Unhex("2b7e151628aed2a6abf7158809cf4f3c")
+		iv    = Unhex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+		plain = Unhex("6bc1bee22e409f96e93d7e117393172a" + "ae2d8a571e03ac9c9eb76fac45af8e51" +
+			"30c81c46a35ce411e5fbc1191a0a52ef" + "f69f2445df4f9b17ad2b417be66c3710")
+		want = Unhex("874d6191b620e3261bef6864990db6ce" + "9806f66b7970fdff8617187bb9fffdff" +
+			"5ae4df3edbd5d35e5b4f09020db03eab" + "1e031dda2fbe03d1792170a0f3009cee")
+	)
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctr, err := NewCTRLayout(b, iv, CTRNIST)
+	if err != nil {
+		t.Fatal("NewCTRLayout failed: ", err)
+	}
+	got := make([]byte, len(plain))
+	ctr.XORKeyStream(got, plain)
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encrypted to %x; want %x", got, want)
+	}
+}
+
+func TestCTR_Seek(t *testing.T) {
+	b, err := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Use an IV that will overflow the low 64 bits to test carrying in CTRNIST.
+	nistIV := Unhex("0123456789abcdeffffffffffffffffe")
+
+	for _, tc := range []struct {
+		layout CTRLayout
+		iv     []byte
+	}{
+		{CTRLittleEndian, []byte("noncenon")},
+		{CTRBigEndian, []byte("noncenon")},
+		{CTRNIST, nistIV},
+	} {
+		// Generate the first part of the keystream sequentially.
+		ctr, err := NewCTRLayout(b, tc.iv, tc.layout)
+		if err != nil {
+			t.Fatalf("NewCTRLayout(%v) failed: %v", tc.layout, err)
+		}
+		full := make([]byte, 100)
+		ctr.XORKeyStream(full, full)
+
+		for _, off := range []int64{0, 1, 15, 16, 17, 50, 99} {
+			if _, err := ctr.Seek(off, io.SeekStart); err != nil {
+				t.Errorf("Layout %v: Seek(%v) failed: %v", tc.layout, off, err)
+				continue
+			}
+			got := make([]byte, len(full)-int(off))
+			ctr.XORKeyStream(got, got)
+			if want := full[off:]; !bytes.Equal(got, want) {
+				t.Errorf("Layout %v: got %x after Seek(%v); want %x", tc.layout, got, off, want)
+			}
+		}
+		// Seek backwards relative to the current position.
+		if pos, err := ctr.Seek(-20, io.SeekCurrent); err != nil {
+			t.Errorf("Layout %v: relative Seek(-20) failed: %v", tc.layout, err)
+		} else if pos != int64(len(full)-20) {
+			t.Errorf("Layout %v: relative Seek(-20) returned %v; want %v", tc.layout, pos, len(full)-20)
+		} else {
+			got := make([]byte, 20)
+			ctr.XORKeyStream(got, got)
+			if want := full[pos:]; !bytes.Equal(got, want) {
+				t.Errorf("Layout %v: got %x after relative Seek(-20); want %x", tc.layout, got, want)
+			}
+		}
+		if _, err := ctr.Seek(-1, io.SeekStart); err == nil {
+			t.Errorf("Layout %v: Seek(-1) unexpectedly succeeded", tc.layout)
+		}
+	}
+
+	// Check that the CTRNIST layout matches crypto/cipher.NewCTR.
+	want := make([]byte, 64)
+	cipher.NewCTR(b, nistIV).XORKeyStream(want, want)
+	ctr, err := NewCTRLayout(b, nistIV, CTRNIST)
+	if err != nil {
+		t.Fatal("NewCTRLayout failed: ", err)
+	}
+	got := make([]byte, len(want))
+	ctr.XORKeyStream(got, got)
+	if !bytes.Equal(got, want) {
+		t.Errorf("CTRNIST produced %x; crypto/cipher produced %x", got, want)
+	}
+}
@@ -5,21 +5,28 @@
 package common
 
 import (
-	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"math/big"
 )
 
 // Unhex decodes the supplied hexadecimal string, panicking on error.
 func Unhex(s string) []byte {
-	b, err := hex.DecodeString(s)
+	b, err := TryUnhex(s)
 	if err != nil {
-		panic(fmt.Sprintf("failed to decode %q: %v", s, err))
+		panic(err)
 	}
 	return b
 }
 
+// TryUnhex is like Unhex but returns an error on failure.
+func TryUnhex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %q: %w", s, err)
+	}
+	return b, nil
+}
+
 // XOR xors a with b. If b is shorter than a, it is repeated.
 func XOR(a, b []byte) []byte {
 	x := make([]byte, len(a))
@@ -70,26 +77,3 @@ func Hamming(a, b []byte) int {
 	}
 	return dist
 }
-
-// RandBytes returns a slice of n cryptographically-secure random bytes.
-func RandBytes(n int) []byte {
-	b := make([]byte, n)
-	if _, err := rand.Read(b); err != nil {
-		panic(err)
-	}
-	return b
-}
-
-// RandInt64 returns a cryptographically-secure random integer in the range [0, n).
-func RandInt64(max int64) int64 {
-	v, err := rand.Int(rand.Reader, big.NewInt(max))
-	if err != nil {
-		panic(err)
-	}
-	return v.Int64()
-}
-
-// RandInt returns a cryptographically-secure random integer in the range [0, n).
-func RandInt(max int) int {
-	return int(RandInt64(int64(max)))
-}
@@ -0,0 +1,301 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// PaddingOracle decrypts ct in CBC mode using iv and reports whether
+// the resulting plaintext has valid padding. PKCS#7 padding is used unless
+// PaddingOracleOptions.Padding specifies a different scheme.
+type PaddingOracle func(iv, ct []byte) bool
+
+// PaddingOracleAttack uses oracle to decrypt ct, which was encrypted in CBC mode
+// using iv and a block cipher with block size bs. The padded plaintext is returned,
+// along with the number of times that oracle was called.
+func PaddingOracleAttack(oracle PaddingOracle, iv, ct []byte, bs int) (plain []byte, queries int, err error) {
+	return PaddingOracleAttackParallel(context.Background(), oracle, iv, ct, bs,
+		&PaddingOracleOptions{Workers: 1})
+}
+
+// ErrQueryBudget is returned when PaddingOracleOptions.MaxQueries is exceeded.
+var ErrQueryBudget = errors.New("oracle query budget exhausted")
+
+// ErrUnsupportedPadding is returned when PaddingOracleOptions.Padding can't be attacked.
+// An oracle for ISO10126Padding only reveals whether the final byte is in range, and one for
+// ZeroPadding reveals nothing at all, so neither leaks enough to decrypt full blocks.
+var ErrUnsupportedPadding = errors.New("padding scheme can't be attacked with an oracle")
+
+// PaddingOracleOptions configures PaddingOracleAttackParallel.
+type PaddingOracleOptions struct {
+	// Workers is the number of blocks to decrypt concurrently.
+	// If zero or negative, runtime.NumCPU is used.
+	Workers int
+	// MaxQueries is the maximum number of times that the oracle will be called across all workers.
+	// If zero, the number of queries is unlimited.
+	MaxQueries int
+	// Interval is the minimum delay between the starts of successive queries across all workers.
+	// If zero, queries are not rate-limited.
+	Interval time.Duration
+	// Padding is the padding scheme checked by the oracle.
+	// If nil, PKCS7Padding is used.
+	Padding Padding
+}
+
+// PaddingOracleAttackParallel is like PaddingOracleAttack, but it decrypts multiple blocks concurrently
+// and can limit the rate and total number of oracle queries. oracle must be safe for concurrent use.
+// If ctx is cancelled or opts.MaxQueries is exceeded, the attack is aborted and an error
+// (ctx.Err() or ErrQueryBudget) is returned. opts may be nil.
+func PaddingOracleAttackParallel(ctx context.Context, oracle PaddingOracle, iv, ct []byte, bs int,
+	opts *PaddingOracleOptions) (plain []byte, queries int, err error) {
+	if opts == nil {
+		opts = &PaddingOracleOptions{}
+	}
+	if len(iv) != bs {
+		return nil, 0, fmt.Errorf("%w: got %v; need %v", ErrBadIVSize, len(iv), bs)
+	}
+	if len(ct)%bs != 0 {
+		return nil, 0, fmt.Errorf("%w: ciphertext size %v isn't multiple of %v", ErrPartialBlock, len(ct), bs)
+	}
+	pad := PKCS7Padding
+	if opts.Padding != nil {
+		pad = opts.Padding
+	}
+	op, ok := pad.(oraclePadding)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %v", ErrUnsupportedPadding, pad)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	q := newOracleQuerier(ctx, oracle, opts.MaxQueries, opts.Interval)
+
+	nw := opts.Workers
+	if nw <= 0 {
+		nw = runtime.NumCPU()
+	}
+	if nb := len(ct) / bs; nw > nb {
+		nw = nb
+	}
+
+	plain = make([]byte, len(ct))
+	blocks := make(chan int, len(ct)/bs) // indexes of blocks to decrypt
+	for i := 0; i < len(ct)/bs; i++ {
+		blocks <- i
+	}
+	close(blocks)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	for i := 0; i < nw; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bi := range blocks {
+				start := bi * bs
+				inter, berr := decryptBlockCBC(q.query, ct[start:start+bs], op)
+				if berr != nil {
+					once.Do(func() {
+						err = fmt.Errorf("block %d: %w", bi, berr)
+						cancel()
+					})
+					return
+				}
+				// During CBC decryption, the intermediate block is XORed with the previous ciphertext block.
+				prev := iv
+				if bi > 0 {
+					prev = ct[start-bs : start]
+				}
+				for j := range inter {
+					plain[start+j] = inter[j] ^ prev[j]
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err != nil {
+		return nil, q.count(), err
+	}
+	return plain, q.count(), nil
+}
+
+// PaddingOracleEncrypt uses oracle to forge an IV and ciphertext that will decrypt to plain
+// (which must already be padded to a multiple of bs) without knowing the key.
+// oracle must check PKCS#7 padding.
+// This is sometimes called CBC-R. The number of times that oracle was called is also returned.
+func PaddingOracleEncrypt(oracle PaddingOracle, plain []byte, bs int) (iv, ct []byte, queries int, err error) {
+	if len(plain)%bs != 0 {
+		return nil, nil, 0, fmt.Errorf("%w: plaintext size %v isn't multiple of %v", ErrPartialBlock, len(plain), bs)
+	}
+
+	// Start with an arbitrary final ciphertext block and work backwards: after decrypting each
+	// ciphertext block to its intermediate state, we can choose the previous ciphertext block
+	// (or the IV) so that it's XORed to produce the desired plaintext.
+	q := newOracleQuerier(context.Background(), oracle, 0, 0)
+	buf := make([]byte, len(plain)+bs)
+	copy(buf[len(plain):], RandBytes(bs))
+	for start := len(plain) - bs; start >= 0; start -= bs {
+		inter, err := decryptBlockCBC(q.query, buf[start+bs:start+2*bs], pkcs7Padding{})
+		if err != nil {
+			return nil, nil, q.count(), fmt.Errorf("block %d: %w", start/bs, err)
+		}
+		for i := range inter {
+			buf[start+i] = inter[i] ^ plain[start+i]
+		}
+	}
+	return buf[:bs], buf[bs:], q.count(), nil
+}
+
+// oracleQuerier wraps a PaddingOracle to count and limit queries.
+// It is safe for concurrent use.
+type oracleQuerier struct {
+	ctx      context.Context
+	oracle   PaddingOracle
+	max      int           // maximum number of queries, or 0 if unlimited
+	interval time.Duration // minimum delay between queries
+
+	mu   sync.Mutex
+	n    int       // number of queries passed to (or reserved for) the oracle
+	next time.Time // earliest time at which the next query can start
+}
+
+func newOracleQuerier(ctx context.Context, oracle PaddingOracle, max int, interval time.Duration) *oracleQuerier {
+	return &oracleQuerier{ctx: ctx, oracle: oracle, max: max, interval: interval}
+}
+
+// query waits until a query is permitted and then passes iv and ct to the oracle.
+func (q *oracleQuerier) query(iv, ct []byte) (bool, error) {
+	if err := q.ctx.Err(); err != nil {
+		return false, err
+	}
+
+	q.mu.Lock()
+	if q.max > 0 && q.n >= q.max {
+		q.mu.Unlock()
+		return false, ErrQueryBudget
+	}
+	q.n++ // reserve a query so concurrent callers can't exceed max
+	var wait time.Duration
+	if q.interval > 0 {
+		now := time.Now()
+		if q.next.Before(now) {
+			q.next = now
+		}
+		wait = q.next.Sub(now)
+		q.next = q.next.Add(q.interval)
+	}
+	q.mu.Unlock()
+
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-q.ctx.Done():
+			t.Stop()
+			// The oracle wasn't queried, so release the reservation.
+			q.mu.Lock()
+			q.n--
+			q.mu.Unlock()
+			return false, q.ctx.Err()
+		}
+	}
+	return q.oracle(iv, ct), nil
+}
+
+// count returns the number of queries that have been passed to the oracle.
+func (q *oracleQuerier) count() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// decryptBlockCBC uses query to get the intermediate state of block, i.e. the block
+// as decrypted by the underlying cipher before it's XORed with the previous ciphertext block.
+// query should pass its arguments to a PaddingOracle that checks padding using p.
+// If it returns an error, decryptBlockCBC returns immediately.
+func decryptBlockCBC(query func(iv, ct []byte) (bool, error), block []byte, p oraclePadding) (
+	inter []byte, err error) {
+	// From challenge 17:
+	//
+	//   The fundamental insight behind this attack is that the byte 01h is valid padding, and occur
+	//   in 1/256 trials of "randomized" plaintexts produced by decrypting a tampered ciphertext.
+	//   - 02h in isolation is not valid padding.
+	//   - 02h 02h is valid padding, but is much less likely to occur randomly than 01h.
+	//   - 03h 03h 03h is even less likely.
+	//   So you can assume that if you corrupt a decryption AND it had valid padding, you know what that padding byte is.
+	//
+	// The general approach for decrypting a block (described here for PKCS#7) is:
+	//
+	// - Manipulate the last byte of the previous block to force the last byte of the block to 0x1,
+	//   representing one byte of padding.
+	// - Now use that knowledge to set the last byte to 0x2, representing two bytes of padding.
+	// - Manipulate the second-to-last byte of the previous block until the padding is valid
+	//   (i.e. that byte is also 0x2).
+	// - Now we know the second-to-last byte of the block.
+	// - Repeat until we know all of the bytes of the block.
+	//
+	// Other schemes work the same way, but with different values for the padding bytes
+	// (see oraclePadding.oracleTail).
+	//
+	// Rather than modifying the real previous block, we pass the block to the oracle on its own
+	// and use our modified previous block as the IV. The block gets XORed against the IV
+	// in the same way that it would've been XORed against the previous block.
+	bs := len(block)
+	inter = make([]byte, bs)
+	mod := make([]byte, bs) // modified previous block, passed as IV
+
+	for pos := bs - 1; pos >= 0; pos-- {
+		// Make the already-known bytes after the target byte decrypt to the padding values.
+		tail, confirm := p.oracleTail(bs - pos)
+		for i := pos + 1; i < bs; i++ {
+			mod[i] = inter[i] ^ tail[i-pos]
+		}
+
+		found := false
+		for i := 0; i < 256 && !found; i++ {
+			mod[pos] = byte(i)
+			valid, err := query(mod, block)
+			if err != nil {
+				return nil, err
+			} else if !valid {
+				continue
+			}
+
+			// Some values of the target byte may only produce valid padding in combination with
+			// specific values of the preceding bytes. For example, with PKCS#7, when we're targeting
+			// the final byte in the block, it may have multiple values that result in valid padding:
+			// - 0x1 (always)
+			// - 0x2 (if preceded by 0x2)
+			// - 0x3 (if preceded by [0x3,0x3])
+			// - etc.
+			// In the 0x1 case, we'll still have valid padding after modifying the preceding byte.
+			// In all other cases, doing this will break the padding.
+			if confirm && pos > 0 {
+				mod[pos-1] ^= 0xff
+				valid, err := query(mod, block)
+				mod[pos-1] ^= 0xff
+				if err != nil {
+					return nil, err
+				} else if !valid {
+					continue
+				}
+			}
+
+			inter[pos] = byte(i) ^ tail[0]
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("failed to decrypt byte %d", pos)
+		}
+	}
+	return inter, nil
+}
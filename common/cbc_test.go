@@ -0,0 +1,185 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestPaddingOracle returns a PaddingOracle using AES-128 with key and PKCS#7 padding.
+func newTestPaddingOracle(key []byte) PaddingOracle {
+	return newTestPaddingOracleScheme(key, PKCS7Padding)
+}
+
+// newTestPaddingOracleScheme is like newTestPaddingOracle but checks padding using p.
+func newTestPaddingOracleScheme(key []byte, p Padding) PaddingOracle {
+	return func(iv, ct []byte) bool {
+		_, err := p.Unpad(DecryptAES(ct, key, iv), len(iv))
+		return err == nil
+	}
+}
+
+func TestPaddingOracleAttack(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+	oracle := newTestPaddingOracle(key)
+
+	for _, plain := range []string{
+		"",
+		"1",
+		"123456789012345",
+		"1234567890123456",
+		"Here's a longer plaintext that spans multiple blocks.",
+		// Ending with "\x02" makes the last byte ambiguous after a block is stripped.
+		"1234567890123456\x02\x02",
+	} {
+		iv := RandBytes(bs)
+		padded := PadPKCS7([]byte(plain), bs)
+		ct := EncryptAES(padded, key, iv)
+		got, queries, err := PaddingOracleAttack(oracle, iv, ct, bs)
+		if err != nil {
+			t.Errorf("PaddingOracleAttack(%q) failed: %v", plain, err)
+		} else if !bytes.Equal(got, padded) {
+			t.Errorf("PaddingOracleAttack(%q) = %q; want %q", plain, got, padded)
+		} else if max := len(ct) * (256 + 1); queries <= 0 || queries > max {
+			t.Errorf("PaddingOracleAttack(%q) reported %v queries; want (0, %v]", plain, queries, max)
+		}
+	}
+
+	if _, _, err := PaddingOracleAttack(oracle, RandBytes(8), RandBytes(bs), bs); err == nil {
+		t.Error("PaddingOracleAttack unexpectedly accepted bad IV")
+	}
+	if _, _, err := PaddingOracleAttack(oracle, RandBytes(bs), RandBytes(bs+1), bs); err == nil {
+		t.Error("PaddingOracleAttack unexpectedly accepted partial block")
+	}
+}
+
+func TestPaddingOracleAttack_Schemes(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+
+	for _, p := range []Padding{PKCS7Padding, X923Padding, ISO7816Padding} {
+		oracle := newTestPaddingOracleScheme(key, p)
+		for _, plain := range []string{
+			"",
+			"123456789012345",
+			"Here's a longer plaintext that spans multiple blocks.",
+			// These produce ambiguous bytes for some schemes.
+			"1234567890123456\x00\x02",
+			"1234567890123456\x80\x00",
+		} {
+			iv := RandBytes(bs)
+			padded := p.Pad([]byte(plain), bs)
+			ct := EncryptAES(padded, key, iv)
+			got, _, err := PaddingOracleAttackParallel(context.Background(), oracle, iv, ct, bs,
+				&PaddingOracleOptions{Padding: p})
+			if err != nil {
+				t.Errorf("%v PaddingOracleAttackParallel(%q) failed: %v", p, plain, err)
+			} else if !bytes.Equal(got, padded) {
+				t.Errorf("%v PaddingOracleAttackParallel(%q) = %q; want %q", p, plain, got, padded)
+			}
+		}
+	}
+
+	for _, p := range []Padding{ISO10126Padding, ZeroPadding} {
+		oracle := newTestPaddingOracleScheme(key, p)
+		iv := RandBytes(bs)
+		ct := EncryptAES(p.Pad([]byte("foo"), bs), key, iv)
+		if _, _, err := PaddingOracleAttackParallel(context.Background(), oracle, iv, ct, bs,
+			&PaddingOracleOptions{Padding: p}); !errors.Is(err, ErrUnsupportedPadding) {
+			t.Errorf("%v PaddingOracleAttackParallel returned %v; want %v", p, err, ErrUnsupportedPadding)
+		}
+	}
+}
+
+func TestPaddingOracleEncrypt(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+	oracle := newTestPaddingOracle(key)
+
+	for _, plain := range []string{
+		"",
+		"admin=true",
+		"Here's a longer plaintext that spans multiple blocks.",
+	} {
+		padded := PadPKCS7([]byte(plain), bs)
+		iv, ct, _, err := PaddingOracleEncrypt(oracle, padded, bs)
+		if err != nil {
+			t.Errorf("PaddingOracleEncrypt(%q) failed: %v", plain, err)
+		} else if got := DecryptAES(ct, key, iv); !bytes.Equal(got, padded) {
+			t.Errorf("PaddingOracleEncrypt(%q) produced ciphertext decrypting to %q", plain, got)
+		}
+	}
+}
+
+func TestPaddingOracleAttackParallel(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+	iv := RandBytes(bs)
+	padded := PadPKCS7([]byte("Here's a plaintext that spans quite a few blocks, so multiple workers are used."), bs)
+	ct := EncryptAES(padded, key, iv)
+
+	var calls int32
+	base := newTestPaddingOracle(key)
+	oracle := func(iv, ct []byte) bool {
+		atomic.AddInt32(&calls, 1)
+		return base(iv, ct)
+	}
+
+	got, queries, err := PaddingOracleAttackParallel(context.Background(), oracle, iv, ct, bs,
+		&PaddingOracleOptions{Workers: 4})
+	if err != nil {
+		t.Fatal("PaddingOracleAttackParallel failed: ", err)
+	}
+	if !bytes.Equal(got, padded) {
+		t.Errorf("PaddingOracleAttackParallel decrypted %q; want %q", got, padded)
+	}
+	if int(calls) != queries {
+		t.Errorf("PaddingOracleAttackParallel reported %v queries; oracle was called %v times", queries, calls)
+	}
+
+	// The attack should stop as soon as the budget is exhausted.
+	const max = 100
+	calls = 0
+	if _, queries, err := PaddingOracleAttackParallel(context.Background(), oracle, iv, ct, bs,
+		&PaddingOracleOptions{Workers: 4, MaxQueries: max}); !errors.Is(err, ErrQueryBudget) {
+		t.Errorf("PaddingOracleAttackParallel with budget returned %v; want %v", err, ErrQueryBudget)
+	} else if queries != max || calls != max {
+		t.Errorf("PaddingOracleAttackParallel with budget made %v queries (%v calls); want %v", queries, calls, max)
+	}
+
+	// Rate-limiting should space out queries. Use a single worker so that no queries are
+	// still waiting to start when the budget is exhausted.
+	const interval = time.Millisecond
+	start := time.Now()
+	if _, _, err := PaddingOracleAttackParallel(context.Background(), oracle, iv, ct, bs,
+		&PaddingOracleOptions{Workers: 1, MaxQueries: max, Interval: interval}); !errors.Is(err, ErrQueryBudget) {
+		t.Errorf("PaddingOracleAttackParallel with interval returned %v; want %v", err, ErrQueryBudget)
+	} else if elapsed, min := time.Since(start), (max-1)*interval; elapsed < min {
+		t.Errorf("PaddingOracleAttackParallel with interval took %v; want at least %v", elapsed, min)
+	}
+
+	// Queries that are cancelled while waiting to start shouldn't be counted.
+	calls = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, queries, err := PaddingOracleAttackParallel(ctx, oracle, iv, ct, bs,
+		&PaddingOracleOptions{Workers: 4, Interval: 20 * time.Millisecond}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("PaddingOracleAttackParallel with timeout returned %v; want %v", err, context.DeadlineExceeded)
+	} else if int(calls) != queries {
+		t.Errorf("PaddingOracleAttackParallel with timeout reported %v queries; oracle was called %v times", queries, calls)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := PaddingOracleAttackParallel(ctx, oracle, iv, ct, bs, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("PaddingOracleAttackParallel with cancelled context returned %v; want %v", err, context.Canceled)
+	}
+}
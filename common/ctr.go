@@ -0,0 +1,206 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"math"
+	"sort"
+)
+
+// BreakFixedNonceCTR recovers the keystream used to encrypt cts, a set of English plaintexts
+// encrypted using CTR mode with the same key and nonce (or any other stream cipher reusing
+// the same keystream). The recovered keystream (with length equal to that of the longest
+// ciphertext) and the decrypted plaintexts are returned.
+//
+// Each keystream byte is first chosen by treating the corresponding column of ciphertext bytes
+// as a single-byte XOR ciphertext. Columns near the ends of the longest ciphertexts contain
+// too few bytes for this to work, and letter frequencies alone can't distinguish uppercase from
+// lowercase, so each column is then refined by choosing the byte that maximizes the likelihood
+// of the bigrams that it forms with its neighbors in every row. Bigram probabilities are learned
+// from the other columns' current decryptions and repeatedly recomputed as columns change.
+// The final few bytes of the longest plaintexts, where only one or two rows contribute,
+// may still be decrypted incorrectly.
+func BreakFixedNonceCTR(cts [][]byte) (keystream []byte, plains [][]byte) {
+	maxLen := 0
+	for _, ct := range cts {
+		if len(ct) > maxLen {
+			maxLen = len(ct)
+		}
+	}
+
+	keystream = make([]byte, maxLen)
+	col := make([]byte, 0, len(cts))
+	for i := range keystream {
+		col = col[:0]
+		for _, ct := range cts {
+			if i < len(ct) {
+				col = append(col, ct[i])
+			}
+		}
+		keystream[i] = SingleByteXOR(col)
+	}
+
+	plains = make([][]byte, len(cts))
+	for i, ct := range cts {
+		plains[i] = XOR(ct, keystream[:len(ct)])
+	}
+
+	const maxPasses = 10
+	m := newBigramModel(plains)
+	for pass := 0; pass < maxPasses; pass++ {
+		changed := false
+		for i := range keystream {
+			// Don't let the column vote for itself: exclude the bigrams that it's part of.
+			m.update(plains, i, -1)
+			m.update(plains, i+1, -1)
+			best, bestScore := keystream[i], math.Inf(-1)
+			for k := 0; k < 256; k++ {
+				if s := m.columnScore(cts, plains, i, byte(k)); s > bestScore {
+					best, bestScore = byte(k), s
+				}
+			}
+			if best != keystream[i] {
+				keystream[i] = best
+				for j, ct := range cts {
+					if i < len(ct) {
+						plains[j][i] = ct[i] ^ best
+					}
+				}
+				changed = true
+			}
+			m.update(plains, i, 1)
+			m.update(plains, i+1, 1)
+		}
+		if !changed {
+			break
+		}
+	}
+	return keystream, plains
+}
+
+// bigramStart is used by bigramModel as the preceding byte for the first byte in each row.
+const bigramStart = 256
+
+// bigramModel is a bigram model learned from rows of text. Bigram probabilities are
+// interpolated with fixed English unigram probabilities.
+type bigramModel struct {
+	counts [257][256]float64 // counts[a][b] is the number of times that b followed a
+	totals [257]float64      // totals[a] is the sum of counts[a]
+	uni    [256]float64      // unigram probabilities for bytes not at start of row
+	start  [256]float64      // unigram probabilities for bytes at start of row
+}
+
+func newBigramModel(rows [][]byte) *bigramModel {
+	m := &bigramModel{
+		uni:   textFreqs(EnglishUpperFreqs, 0.05),
+		start: textFreqs(EnglishUpperFreqs, 0.9), // lines usually start with capital letters
+	}
+	maxLen := 0
+	for _, r := range rows {
+		if len(r) > maxLen {
+			maxLen = len(r)
+		}
+	}
+	for i := 0; i < maxLen; i++ {
+		m.update(rows, i, 1)
+	}
+	return m
+}
+
+// update adds delta to the counts of bigrams ending at column i in rows.
+func (m *bigramModel) update(rows [][]byte, i int, delta float64) {
+	for _, r := range rows {
+		if i >= len(r) {
+			continue
+		}
+		a := bigramStart
+		if i > 0 {
+			a = int(r[i-1])
+		}
+		m.counts[a][r[i]] += delta
+		m.totals[a] += delta
+	}
+}
+
+// logProb returns the log-probability of b following a (which may be bigramStart).
+func (m *bigramModel) logProb(a int, b byte) float64 {
+	fb := m.uni[b]
+	if a == bigramStart {
+		fb = m.start[b]
+	}
+	// Witten-Bell-style interpolation: trust the bigram counts more as a becomes more common.
+	const k = 4
+	t := m.totals[a]
+	if t <= 0 {
+		return math.Log(fb)
+	}
+	l := t / (t + k)
+	return math.Log(l*m.counts[a][b]/t + (1-l)*fb)
+}
+
+// columnScore returns the log-likelihood of the bigrams involving column i across all
+// rows if ks is used as the keystream byte for column i.
+func (m *bigramModel) columnScore(cts, plains [][]byte, i int, ks byte) float64 {
+	var sum float64
+	for j, ct := range cts {
+		if i >= len(ct) {
+			continue
+		}
+		p := ct[i] ^ ks
+		a := bigramStart
+		if i > 0 {
+			a = int(plains[j][i-1])
+		}
+		sum += m.logProb(a, p)
+		if i+1 < len(ct) {
+			sum += m.logProb(int(p), plains[j][i+1])
+		}
+	}
+	return sum
+}
+
+// CribPlacement describes a position at which a crib (known or guessed plaintext)
+// was placed by DragCrib.
+type CribPlacement struct {
+	Row, Pos  int     // row and byte offset of the crib's first byte
+	Keystream []byte  // keystream bytes implied for Pos through Pos+len(crib)-1
+	Score     float64 // average per-byte score of the other rows' resulting plaintext
+}
+
+// DragCrib tries crib at every position in every ciphertext in cts, which were encrypted
+// using the same keystream. Each placement implies a run of keystream bytes, which are used
+// to decrypt the same columns in all of the other ciphertexts. The resulting plaintext
+// fragments are scored using s (DefaultScorer if nil), and placements are returned
+// ordered from best to worst. Placements that don't produce any other plaintext are omitted.
+func DragCrib(cts [][]byte, crib []byte, s Scorer) []CribPlacement {
+	if s == nil {
+		s = DefaultScorer
+	}
+	var pls []CribPlacement
+	for row, ct := range cts {
+		for pos := 0; pos+len(crib) <= len(ct); pos++ {
+			ks := XOR(ct[pos:pos+len(crib)], crib)
+			var sum float64
+			var n int
+			for j, other := range cts {
+				if j == row || pos >= len(other) {
+					continue
+				}
+				end := pos + len(ks)
+				if end > len(other) {
+					end = len(other)
+				}
+				frag := XOR(other[pos:end], ks)
+				sum += s.Score(frag)
+				n += len(frag)
+			}
+			if n > 0 {
+				pls = append(pls, CribPlacement{Row: row, Pos: pos, Keystream: ks, Score: sum / float64(n)})
+			}
+		}
+	}
+	sort.SliceStable(pls, func(i, j int) bool { return pls[i].Score > pls[j].Score })
+	return pls
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"testing"
+)
+
+// testFixedNonceLines contains the plaintexts from challenge 19.
+var testFixedNonceLines = []string{
+	"I have met them at close of day",
+	"Coming with vivid faces",
+	"From counter or desk among grey",
+	"Eighteenth-century houses.",
+	"I have passed with a nod of the head",
+	"Or polite meaningless words,",
+	"Or have lingered awhile and said",
+	"Polite meaningless words,",
+	"And thought before I had done",
+	"Of a mocking tale or a gibe",
+	"To please a companion",
+	"Around the fire at the club,",
+	"Being certain that they and I",
+	"But lived where motley is worn:",
+	"All changed, changed utterly:",
+	"A terrible beauty is born.",
+	"That woman's days were spent",
+	"In ignorant good will,",
+	"Her nights in argument",
+	"Until her voice grew shrill.",
+	"What voice more sweet than hers",
+	"When young and beautiful,",
+	"She rode to harriers?",
+	"This man had kept a school",
+	"And rode our winged horse.",
+	"This other his helper and friend",
+	"Was coming into his force;",
+	"He might have won fame in the end,",
+	"So sensitive his nature seemed,",
+	"So daring and sweet his thought.",
+	"This other man I had dreamed",
+	"A drunken, vain-glorious lout.",
+	"He had done most bitter wrong",
+	"To some who are near my heart,",
+	"Yet I number him in the song;",
+	"He, too, has resigned his part",
+	"In the casual comedy;",
+	"He, too, has been changed in his turn,",
+	"Transformed utterly:",
+	"A terrible beauty is born.",
+}
+
+// encryptFixedNonceLines encrypts each of testFixedNonceLines using CTR mode with
+// a random key and a nonce of 0. The ciphertexts are returned along with the
+// keystream, which is as long as the longest line.
+func encryptFixedNonceLines() (cts [][]byte, ks []byte) {
+	ctr := NewCTR(RandBytes(16), 0)
+	cts = make([][]byte, len(testFixedNonceLines))
+	for i, ln := range testFixedNonceLines {
+		ctr.Reset()
+		cts[i] = make([]byte, len(ln))
+		ctr.XORKeyStream(cts[i], []byte(ln))
+		if len(ln) > len(ks) {
+			ks = make([]byte, len(ln))
+		}
+	}
+	ctr.Reset()
+	ctr.XORKeyStream(ks, ks)
+	return cts, ks
+}
+
+func TestBreakFixedNonceCTR(t *testing.T) {
+	cts, want := encryptFixedNonceLines()
+	got, plains := BreakFixedNonceCTR(cts)
+	if len(got) != len(want) {
+		t.Fatalf("Got %d-byte keystream; want %d bytes", len(got), len(want))
+	}
+
+	// The last few bytes of the longest lines may be wrong, but every column containing
+	// at least three bytes should be recovered.
+	const minRows = 3
+	for i := range want {
+		n := 0
+		for _, ct := range cts {
+			if i < len(ct) {
+				n++
+			}
+		}
+		if n >= minRows && got[i] != want[i] {
+			t.Errorf("Keystream byte %d (from %d rows) is %#x; want %#x", i, n, got[i], want[i])
+		}
+	}
+	for i, ct := range cts {
+		if want := XOR(ct, got); !bytes.Equal(plains[i], want) {
+			t.Errorf("Plaintext %d is %q; want %q", i, plains[i], want)
+		}
+	}
+}
+
+func TestDragCrib(t *testing.T) {
+	cts, ks := encryptFixedNonceLines()
+
+	// "terrible beauty" appears at offset 2 in two lines.
+	const crib = "terrible beauty"
+	pls := DragCrib(cts, []byte(crib), nil)
+	if len(pls) == 0 {
+		t.Fatal("DragCrib returned no placements")
+	}
+	if p := pls[0]; p.Pos != 2 || !bytes.Equal(p.Keystream, ks[2:2+len(crib)]) {
+		t.Errorf("DragCrib(%q) placed crib at row %d, pos %d", crib, p.Row, p.Pos)
+	}
+	for i := 1; i < len(pls); i++ {
+		if pls[i].Score > pls[i-1].Score {
+			t.Errorf("DragCrib(%q) returned misordered placements %d and %d", crib, i-1, i)
+		}
+	}
+}
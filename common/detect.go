@@ -0,0 +1,194 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// Mode describes a cipher's mode of operation.
+type Mode int
+
+const (
+	ModeUnknown Mode = iota
+	ModeECB
+	ModeCBC    // CBC or another mode that chains blocks together
+	ModeStream // CTR or another stream cipher, where ciphertext length matches plaintext length
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeECB:
+		return "ECB"
+	case ModeCBC:
+		return "CBC"
+	case ModeStream:
+		return "stream"
+	default:
+		return "unknown"
+	}
+}
+
+// ModeGuess describes the mode that a cipher appears to be using.
+type ModeGuess struct {
+	Mode       Mode
+	BlockSize  int     // block size in bytes, or 0 if unknown (or 1 for ModeStream)
+	Confidence float64 // in [0.0, 1.0]
+	// Deterministic is true if encrypting the same input multiple times produced the same ciphertext.
+	// This is false if e.g. a random IV or prefix is used. It is only set by DetectMode.
+	Deterministic bool
+}
+
+// DetectMode makes repeated calls to f to determine which mode it uses.
+// f may use a prefix and/or suffix, and its key, IV, and prefix may change on each call.
+func DetectMode(f EncryptFunc) ModeGuess {
+	const (
+		maxBlockSize = 64
+		trials       = 8
+	)
+
+	// Look at how the ciphertext length grows as the plaintext grows. Block ciphers produce
+	// ciphertexts whose lengths are multiples of the block size (even if the prefix length changes),
+	// so the GCD of the differences between lengths is the block size.
+	lens := make([]int, 2*maxBlockSize+1)
+	bs := 0
+	for i := range lens {
+		lens[i] = len(f(A(i)))
+		if d := lens[i] - lens[0]; d != 0 {
+			bs = gcd(bs, d)
+		}
+	}
+	if bs == 0 {
+		return ModeGuess{Mode: ModeUnknown}
+	}
+
+	in := A(4 * bs)
+	g := ModeGuess{BlockSize: bs, Deterministic: bytes.Equal(f(in), f(in))}
+
+	if bs == 1 {
+		// Check how often adding a byte of input also adds exactly one byte of output.
+		ones := 0
+		for i := 1; i < len(lens); i++ {
+			if lens[i]-lens[i-1] == 1 {
+				ones++
+			}
+		}
+		g.Mode = ModeStream
+		g.Confidence = float64(ones) / float64(len(lens)-1)
+		return g
+	}
+
+	// Four blocks of identical input include at least two aligned identical blocks no matter
+	// how long the prefix is. In ECB mode, they'll produce identical consecutive ciphertext blocks.
+	ecb := 0
+	for i := 0; i < trials; i++ {
+		if hasRepeatedBlock(f(in), bs, true) {
+			ecb++
+		}
+	}
+	if 2*ecb > trials {
+		g.Mode = ModeECB
+		g.Confidence = float64(ecb) / trials
+	} else {
+		g.Mode = ModeCBC
+		g.Confidence = float64(trials-ecb) / trials
+	}
+	return g
+}
+
+// DetectModeCiphertext examines ct, produced by a cipher with block size bs,
+// and guesses which mode was used without making any further calls to the cipher.
+// This relies on ECB producing repeated ciphertext blocks for repeated plaintext blocks.
+// If ct doesn't look like ECB or a stream cipher, ModeUnknown is returned.
+// It panics on failure; see TryDetectModeCiphertext.
+func DetectModeCiphertext(ct []byte, bs int) ModeGuess {
+	g, err := TryDetectModeCiphertext(ct, bs)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// TryDetectModeCiphertext is like DetectModeCiphertext but returns an error wrapping
+// ErrBadBlockSize if bs isn't positive.
+func TryDetectModeCiphertext(ct []byte, bs int) (ModeGuess, error) {
+	if bs <= 0 {
+		return ModeGuess{}, fmt.Errorf("%w %v", ErrBadBlockSize, bs)
+	}
+	if len(ct)%bs != 0 {
+		// Block modes with padding always produce full blocks.
+		return ModeGuess{Mode: ModeStream, BlockSize: 1, Confidence: 1}, nil
+	}
+	if hasRepeatedBlock(ct, bs, false) {
+		// Estimate the probability of the repeat occurring by chance with random-looking blocks.
+		n := float64(len(ct) / bs)
+		p := n * (n - 1) / 2 * math.Pow(2, -8*float64(bs))
+		return ModeGuess{Mode: ModeECB, BlockSize: bs, Confidence: math.Max(0, 1-p)}, nil
+	}
+	return ModeGuess{Mode: ModeUnknown}, nil
+}
+
+// ClassifyCiphertexts calls DetectModeCiphertext for each ciphertext in cts.
+// It panics on failure; see TryClassifyCiphertexts.
+func ClassifyCiphertexts(cts [][]byte, bs int) []ModeGuess {
+	gs, err := TryClassifyCiphertexts(cts, bs)
+	if err != nil {
+		panic(err)
+	}
+	return gs
+}
+
+// TryClassifyCiphertexts is like ClassifyCiphertexts but returns an error wrapping
+// ErrBadBlockSize if bs isn't positive.
+func TryClassifyCiphertexts(cts [][]byte, bs int) ([]ModeGuess, error) {
+	if bs <= 0 {
+		return nil, fmt.Errorf("%w %v", ErrBadBlockSize, bs)
+	}
+	gs := make([]ModeGuess, len(cts))
+	for i, ct := range cts {
+		var err error
+		if gs[i], err = TryDetectModeCiphertext(ct, bs); err != nil {
+			return nil, err
+		}
+	}
+	return gs, nil
+}
+
+// hasRepeatedBlock returns true if any bs-sized block appears more than once in b.
+// If consecutive is true, only consecutive blocks are compared.
+func hasRepeatedBlock(b []byte, bs int, consecutive bool) bool {
+	seen := make(map[string]struct{})
+	for start := 0; start+bs <= len(b); start += bs {
+		block := b[start : start+bs]
+		if consecutive {
+			if start >= bs && bytes.Equal(block, b[start-bs:start]) {
+				return true
+			}
+			continue
+		}
+		if _, ok := seen[string(block)]; ok {
+			return true
+		}
+		seen[string(block)] = struct{}{}
+	}
+	return false
+}
+
+// gcd returns the greatest common divisor of a and b.
+// Negative values are treated as positive.
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
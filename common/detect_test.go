@@ -0,0 +1,94 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDetectMode(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+	fixedIV := RandBytes(bs)
+	fixedPre := RandBytes(5)
+
+	// wrap returns an EncryptFunc that adds the prefix returned by pre and a short suffix
+	// to its input and passes the result to enc.
+	wrap := func(pre func() []byte, enc func(b []byte) []byte) EncryptFunc {
+		return func(b []byte) []byte {
+			plain := append(append([]byte{}, pre()...), b...)
+			return enc(append(plain, []byte("suffix")...))
+		}
+	}
+	noPre := func() []byte { return nil }
+	randPre := func() []byte { return RandBytes(5 + RandInt(6)) }
+	ecb := func(b []byte) []byte { return EncryptAES(PadPKCS7(b, bs), key, nil) }
+	cbcFixed := func(b []byte) []byte { return EncryptAES(PadPKCS7(b, bs), key, fixedIV) }
+	cbcRand := func(b []byte) []byte { return EncryptAES(PadPKCS7(b, bs), key, RandBytes(bs)) }
+	ctr := func(b []byte) []byte {
+		var enc bytes.Buffer
+		if err := NewCTR(key, 0).Process(bytes.NewReader(b), &enc); err != nil {
+			panic(err)
+		}
+		return enc.Bytes()
+	}
+
+	for _, tc := range []struct {
+		desc    string
+		f       EncryptFunc
+		mode    Mode
+		bs      int
+		det     bool
+		minConf float64
+	}{
+		{"ECB", wrap(noPre, ecb), ModeECB, bs, true, 1},
+		{"ECB with fixed prefix", wrap(func() []byte { return fixedPre }, ecb), ModeECB, bs, true, 1},
+		{"ECB with random prefix", wrap(randPre, ecb), ModeECB, bs, false, 1},
+		{"CBC with fixed IV", wrap(noPre, cbcFixed), ModeCBC, bs, true, 1},
+		{"CBC with random IV", wrap(noPre, cbcRand), ModeCBC, bs, false, 1},
+		{"CBC with random prefix", wrap(randPre, cbcFixed), ModeCBC, bs, false, 1},
+		{"CTR", wrap(noPre, ctr), ModeStream, 1, true, 1},
+	} {
+		g := DetectMode(tc.f)
+		if g.Mode != tc.mode || g.BlockSize != tc.bs || g.Deterministic != tc.det || g.Confidence < tc.minConf {
+			t.Errorf("DetectMode(%v) = %+v; want mode %v, block size %v, deterministic %v, confidence >= %v",
+				tc.desc, g, tc.mode, tc.bs, tc.det, tc.minConf)
+		}
+	}
+}
+
+func TestClassifyCiphertexts(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+	plain := PadPKCS7(bytes.Repeat([]byte("0123456789abcdef"), 3), bs)
+	var ctr bytes.Buffer
+	if err := NewCTR(key, 0).Process(bytes.NewReader(plain[:len(plain)-1]), &ctr); err != nil {
+		t.Fatal(err)
+	}
+
+	cts := [][]byte{
+		EncryptAES(plain, key, nil),
+		EncryptAES(plain, key, RandBytes(bs)),
+		ctr.Bytes(),
+		RandBytes(4 * bs),
+	}
+	want := []Mode{ModeECB, ModeUnknown, ModeStream, ModeUnknown}
+	for i, g := range ClassifyCiphertexts(cts, bs) {
+		if g.Mode != want[i] {
+			t.Errorf("Ciphertext %d classified as %v; want %v", i, g.Mode, want[i])
+		}
+	}
+
+	for _, bs := range []int{0, -1} {
+		if _, err := TryClassifyCiphertexts(cts, bs); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("TryClassifyCiphertexts with block size %v returned %v; want %v", bs, err, ErrBadBlockSize)
+		}
+		if _, err := TryDetectModeCiphertext(cts[0], bs); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("TryDetectModeCiphertext with block size %v returned %v; want %v", bs, err, ErrBadBlockSize)
+		}
+	}
+}
@@ -6,10 +6,21 @@ package common
 
 import (
 	"bytes"
+	"fmt"
 )
 
 // BlockSizeECB infers the block size used by f, an ECB function.
+// It panics on failure; see TryBlockSizeECB.
 func BlockSizeECB(f EncryptFunc) int {
+	bs, err := TryBlockSizeECB(f)
+	if err != nil {
+		panic(err)
+	}
+	return bs
+}
+
+// TryBlockSizeECB is like BlockSizeECB but returns ErrNoBlockSize on failure.
+func TryBlockSizeECB(f EncryptFunc) (int, error) {
 	const (
 		bufLen       = 1024
 		minBlockSize = 4
@@ -30,18 +41,33 @@ func BlockSizeECB(f EncryptFunc) int {
 			} else {
 				blockCount++
 				if blockCount >= numNeeded {
-					return bs
+					return bs, nil
 				}
 			}
 		}
 	}
-	panic("couldn't find block size")
+	return 0, ErrNoBlockSize
 }
 
 // NextSuffixByteECB attacks f to find the next byte in a fixed suffix.
 // The bytes decoded so far should be passed in known.
-// f should not use a fixed prefix.
+// f should not use a fixed prefix (see DecryptSuffixECB).
+// It panics on failure; see TryNextSuffixByteECB.
 func NextSuffixByteECB(f EncryptFunc, bs int, known []byte) byte {
+	b, err := TryNextSuffixByteECB(f, bs, known)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TryNextSuffixByteECB is like NextSuffixByteECB but returns ErrNextByteNotFound on failure.
+func TryNextSuffixByteECB(f EncryptFunc, bs int, known []byte) (byte, error) {
+	return nextSuffixByteECB(tryEncrypt(f), bs, known)
+}
+
+// nextSuffixByteECB implements TryNextSuffixByteECB. Errors returned by f are returned as-is.
+func nextSuffixByteECB(f tryEncryptFunc, bs int, known []byte) (byte, error) {
 	// This code is gnarly. With a block size of 4:
 	// ''      -> AAAx (pad=3, block=0)
 	// '1'     -> AA1x (pad=2, block=0)
@@ -59,8 +85,14 @@ func NextSuffixByteECB(f EncryptFunc, bs int, known []byte) byte {
 
 	// Get the encrypted block ending in the byte that we want.
 	pad := A(numPad)
-	enc := f(pad)
+	enc, err := f(pad)
+	if err != nil {
+		return 0, err
+	}
 	start := (len(pad) + len(known)) / bs * bs
+	if start+bs > len(enc) {
+		return 0, ErrNextByteNotFound
+	}
 	target := enc[start : start+bs]
 
 	// Now get the plaintext that produced the encrypted block.
@@ -71,9 +103,157 @@ func NextSuffixByteECB(f EncryptFunc, bs int, known []byte) byte {
 	// Figure out what the last byte is.
 	for i := 0; i < 256; i++ {
 		plain[len(plain)-1] = byte(i)
-		if enc := f(plain); bytes.Equal(enc[:bs], target) {
-			return byte(i)
+		if enc, err := f(plain); err != nil {
+			return 0, err
+		} else if len(enc) >= bs && bytes.Equal(enc[:bs], target) {
+			return byte(i), nil
 		}
 	}
-	panic("didn't find next byte")
+	return 0, ErrNextByteNotFound
+}
+
+// DecryptSuffixECB attacks f, an ECB function, to decrypt its entire fixed suffix.
+// f may use a fixed prefix, or a prefix whose content and length change on each call.
+// It panics on failure; see TryDecryptSuffixECB.
+func DecryptSuffixECB(f EncryptFunc) []byte {
+	suf, err := TryDecryptSuffixECB(f)
+	if err != nil {
+		panic(err)
+	}
+	return suf
+}
+
+// TryDecryptSuffixECB is like DecryptSuffixECB but returns an error on failure.
+func TryDecryptSuffixECB(f EncryptFunc) ([]byte, error) {
+	bs, err := TryBlockSizeECB(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wrap f to eliminate its prefix.
+	var g tryEncryptFunc
+	if randomPrefixECB(f, bs) {
+		if g, err = alignRandomPrefixECB(f, bs); err != nil {
+			return nil, err
+		}
+	} else {
+		pl, err := TryPrefixLen(f, bs)
+		if err != nil {
+			return nil, err
+		}
+		pad := (bs - pl%bs) % bs // bytes needed to start a new block
+		g = func(b []byte) ([]byte, error) {
+			enc := f(append(A(pad), b...))
+			return enc[pl+pad:], nil
+		}
+	}
+
+	// Knowing the suffix's length lets us stop before we reach the PKCS#7 padding,
+	// which would otherwise be decrypted as if it were part of the suffix.
+	// Since g doesn't use a prefix, its fixed length is the suffix's length.
+	sl, err := fixedLen(g, bs)
+	if err != nil {
+		return nil, err
+	}
+	known := make([]byte, 0, sl)
+	for len(known) < sl {
+		b, err := nextSuffixByteECB(g, bs, known)
+		if err != nil {
+			return nil, err
+		}
+		known = append(known, b)
+	}
+	return known, nil
+}
+
+// randomPrefixECB returns true if f, an ECB function, appears to use a different prefix
+// each time that it's called.
+func randomPrefixECB(f EncryptFunc, bs int) bool {
+	const tries = 4
+	b := A(3 * bs)
+	first := f(b)
+	for i := 1; i < tries; i++ {
+		if !bytes.Equal(f(b), first) {
+			return true
+		}
+	}
+	return false
+}
+
+// alignRandomPrefixECB wraps f, an ECB function that uses a random prefix, and returns a function
+// that strips the prefix from f's output.
+//
+// Each input is preceded by alignment padding, two copies of a random marker block, and a
+// slightly-different third block. f is called repeatedly until the prefix and padding happen to end
+// at a block boundary so the encrypted markers appear as two identical consecutive blocks, at which
+// point everything after the third block is the encrypted input and suffix. The amount of padding
+// is also varied on each attempt, so this works for prefixes whose lengths modulo bs are fixed.
+// This may fail if the suffix contains repeated blocks.
+//
+// ErrPrefixUnalignable is returned if the encrypted marker block can't be found.
+// The returned function returns an error wrapping ErrPrefixUnalignable if it's unable to align
+// the prefix.
+func alignRandomPrefixECB(f EncryptFunc, bs int) (tryEncryptFunc, error) {
+	// If the markers are misaligned by n bytes, the block containing the end of the padding and the
+	// start of the first marker would match the next block if the final n bytes of the padding
+	// matched the end of the marker. The padding is never empty and always ends with 'A', so make
+	// sure that the marker doesn't.
+	marker := RandBytes(bs)
+	if marker[bs-1] == 'A' {
+		marker[bs-1] = 'B'
+	}
+	// Similarly, the block spanning the two markers would match the next block if the marker was
+	// followed by its own first bs-n bytes, so follow it with a block with a different first byte.
+	// Its last byte also differs so that the block spanning it won't match the next one.
+	guard := append([]byte{}, marker...)
+	guard[0] ^= 0xff
+	guard[bs-1] ^= 0xff
+
+	// Give up eventually in case f doesn't actually use ECB.
+	const maxTries = 1000
+
+	// call passes b to f after the padding, markers, and guard. If identical consecutive blocks
+	// are found in the output, the first one is returned along with everything after the guard.
+	call := func(b []byte, try int) (block, rest []byte) {
+		pad := 1 + try%bs
+		in := make([]byte, 0, pad+3*bs+len(b))
+		in = append(in, A(pad)...)
+		in = append(in, marker...)
+		in = append(in, marker...)
+		in = append(in, guard...)
+		in = append(in, b...)
+		enc := f(in)
+		for start := 0; start+3*bs <= len(enc); start += bs {
+			if bytes.Equal(enc[start:start+bs], enc[start+bs:start+2*bs]) {
+				return enc[start : start+bs], enc[start+3*bs:]
+			}
+		}
+		return nil, nil
+	}
+
+	// Identical blocks can still appear later in the output when the markers are misaligned,
+	// e.g. if the input and suffix both contain the same data. Find the encrypted marker block
+	// by waiting until we've seen the same identical blocks twice with an empty input.
+	var encMarker []byte
+	seen := make(map[string]bool)
+	for i := 0; i < maxTries && encMarker == nil; i++ {
+		if block, _ := call(nil, i); block != nil {
+			if seen[string(block)] {
+				encMarker = block
+			}
+			seen[string(block)] = true
+		}
+	}
+	if encMarker == nil {
+		return nil, fmt.Errorf("%w: couldn't find encrypted marker block", ErrPrefixUnalignable)
+	}
+
+	return func(b []byte) ([]byte, error) {
+		for i := 0; i < maxTries; i++ {
+			if block, rest := call(b, i); bytes.Equal(block, encMarker) {
+				return rest, nil
+			}
+		}
+		return nil, fmt.Errorf("%w after %v attempts", ErrPrefixUnalignable, maxTries)
+	}, nil
 }
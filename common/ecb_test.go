@@ -0,0 +1,84 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecryptSuffixECB(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+
+	// newFunc returns an EncryptFunc that encrypts the concatenation of the prefix
+	// returned by pre, b, and suf.
+	newFunc := func(pre func() []byte, suf string) EncryptFunc {
+		return func(b []byte) []byte {
+			p := pre()
+			plain := make([]byte, 0, len(p)+len(b)+len(suf))
+			plain = append(plain, p...)
+			plain = append(plain, b...)
+			plain = append(plain, []byte(suf)...)
+			return EncryptAES(PadPKCS7(plain, bs), key, nil)
+		}
+	}
+	fixed := func(n int) func() []byte {
+		p := RandBytes(n)
+		return func() []byte { return p }
+	}
+
+	const (
+		almost = "123456789012345"
+		full   = "1234567890123456"
+		long   = "Here's a secret suffix that spans multiple blocks.\x01"
+	)
+	for _, tc := range []struct {
+		desc string
+		pre  func() []byte
+		suf  string
+	}{
+		{"no prefix", fixed(0), long},
+		{"short prefix", fixed(3), long},
+		{"block prefix", fixed(bs), long},
+		{"long prefix", fixed(2*bs + 5), long},
+		{"empty suffix", fixed(5), ""},
+		{"almost-block suffix", fixed(5), almost},
+		{"full-block suffix", fixed(5), full},
+		{"random prefix", func() []byte { return RandBytes(RandInt(3 * bs)) }, long},
+		{"random prefix with fixed length", func() []byte { return RandBytes(7) }, long},
+	} {
+		f := newFunc(tc.pre, tc.suf)
+		if got := DecryptSuffixECB(f); !bytes.Equal(got, []byte(tc.suf)) {
+			t.Errorf("DecryptSuffixECB with %v = %q; want %q", tc.desc, got, tc.suf)
+		}
+	}
+}
+
+func TestTryDecryptSuffixECB_Errors(t *testing.T) {
+	const bs = 16
+	key := RandBytes(bs)
+	iv := RandBytes(bs)
+	cbc := func(b []byte) []byte { return EncryptAES(PadPKCS7(b, bs), key, iv) }
+	if _, err := TryDecryptSuffixECB(cbc); !errors.Is(err, ErrNoBlockSize) {
+		t.Errorf("TryDecryptSuffixECB with CBC returned %v; want %v", err, ErrNoBlockSize)
+	}
+
+	// Use a random prefix and start returning garbage after enough calls for the
+	// prefix to have been aligned once.
+	calls := 0
+	flaky := func(b []byte) []byte {
+		plain := append(RandBytes(RandInt(bs)), b...)
+		plain = PadPKCS7(append(plain, "secret suffix"...), bs)
+		if calls++; calls > 300 {
+			return RandBytes(len(plain))
+		}
+		return EncryptAES(plain, key, nil)
+	}
+	if _, err := TryDecryptSuffixECB(flaky); !errors.Is(err, ErrPrefixUnalignable) {
+		t.Errorf("TryDecryptSuffixECB with unalignable prefix returned %v; want %v", err, ErrPrefixUnalignable)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import "errors"
+
+// Sentinel errors returned (possibly wrapped) by functions in this package.
+// Use errors.Is to check for them.
+var (
+	ErrBadIVSize         = errors.New("bad IV size")
+	ErrPartialBlock      = errors.New("buffer size isn't multiple of block size")
+	ErrShortOutput       = errors.New("output buffer is too small")
+	ErrBadPadding        = errors.New("invalid padding")
+	ErrNoBlockSize       = errors.New("couldn't find block size")
+	ErrNoModBlock        = errors.New("couldn't find modifiable block")
+	ErrFixedLenNotFound  = errors.New("couldn't find fixed length")
+	ErrPrefixNotFound    = errors.New("couldn't find prefix length")
+	ErrNextByteNotFound  = errors.New("didn't find next byte")
+	ErrPrefixUnalignable = errors.New("couldn't align random prefix")
+	ErrNoWords           = errors.New("no words found")
+	ErrNotEnoughOutputs  = errors.New("not enough outputs")
+	ErrBadOutputs        = errors.New("outputs are inconsistent")
+	ErrNotSeeded         = errors.New("state wasn't produced by seed")
+	ErrSeedNotFound      = errors.New("seed not found")
+	ErrBadMACSize        = errors.New("bad MAC size")
+	ErrBadBlockSize      = errors.New("bad block size")
+)
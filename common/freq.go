@@ -47,6 +47,39 @@ func init() {
 		counts[b] = c
 	}
 	EnglishUpperFreqs = ByteFreqs(counts)
+
+	// https://en.wikipedia.org/wiki/Letter_frequency#Relative_frequencies_of_letters_in_other_languages
+	// Accented letters are omitted.
+	FrenchUpperFreqs = percentFreqs([26]float64{
+		7.636, 0.901, 3.260, 3.669, 14.715, 1.066, 0.866, 0.737, 7.529, 0.613, 0.074, 5.456, 2.968,
+		7.095, 5.796, 2.521, 1.362, 6.693, 7.948, 7.244, 6.311, 1.838, 0.049, 0.427, 0.128, 0.326,
+	})
+	GermanUpperFreqs = percentFreqs([26]float64{
+		6.516, 1.886, 2.732, 5.076, 16.396, 1.656, 3.009, 4.577, 6.550, 0.268, 1.417, 3.437, 2.534,
+		9.776, 2.594, 0.670, 0.018, 7.003, 7.270, 6.154, 4.166, 0.846, 1.921, 0.034, 0.039, 1.134,
+	})
+	SpanishUpperFreqs = percentFreqs([26]float64{
+		11.525, 2.215, 4.019, 5.010, 12.181, 0.692, 1.768, 0.703, 6.247, 0.493, 0.011, 4.967, 3.157,
+		6.712, 8.683, 2.510, 0.877, 6.871, 7.977, 4.632, 2.927, 1.138, 0.017, 0.215, 1.008, 0.467,
+	})
+}
+
+// FrenchUpperFreqs, GermanUpperFreqs, and SpanishUpperFreqs are like EnglishUpperFreqs
+// but for other languages.
+var FrenchUpperFreqs, GermanUpperFreqs, SpanishUpperFreqs [256]float64
+
+// percentFreqs returns a normalized table of frequencies of 'A' through 'Z'
+// given their percentages.
+func percentFreqs(pct [26]float64) [256]float64 {
+	var total float64
+	for _, p := range pct {
+		total += p
+	}
+	var bf [256]float64
+	for i, p := range pct {
+		bf['A'+i] = p / total
+	}
+	return bf
 }
 
 // CountBytes returns an array containing the number of times each byte occurs in buf.
@@ -127,13 +160,18 @@ func EnglishScore(b []byte) Score {
 // SingleByteXOR tries to find the byte that's most likely to have been used for single-byte
 // XOR encryption of English text.
 func SingleByteXOR(enc []byte) byte {
+	return SingleByteXORScorer(enc, DefaultScorer)
+}
+
+// SingleByteXORScorer is like SingleByteXOR, but it uses s to score candidate plaintexts.
+func SingleByteXORScorer(enc []byte, s Scorer) byte {
 	var bestKey byte
-	var bestScore *Score
+	bestScore := math.Inf(-1)
 	for i := 0; i < 256; i++ {
 		dec := XOR(enc, []byte{byte(i)})
-		if score := EnglishScore(dec); score.Better(bestScore) {
+		if score := s.Score(dec); score > bestScore {
 			bestKey = byte(i)
-			bestScore = &score
+			bestScore = score
 		}
 	}
 	return bestKey
@@ -0,0 +1,121 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	"github.com/derat/cryptopals/md4"
+	"github.com/derat/cryptopals/md5"
+	"github.com/derat/cryptopals/sha1"
+	"github.com/derat/cryptopals/sha256"
+)
+
+// HashAlg identifies a Merkle–Damgård hash function supported by LengthExtend.
+type HashAlg int
+
+const (
+	SHA1 HashAlg = iota
+	MD4
+	SHA256
+	MD5
+)
+
+// hashAlgInfo describes how to length-extend a HashAlg.
+type hashAlgInfo struct {
+	name      string
+	size      int              // digest size in bytes
+	bo        binary.ByteOrder // used for state words and padding length
+	new       func() hash.Hash
+	fromState func(state []uint32, processedLen uint64) hash.Hash
+}
+
+var hashAlgs = map[HashAlg]hashAlgInfo{
+	SHA1: {"SHA-1", sha1.Size, binary.BigEndian, sha1.New, func(state []uint32, n uint64) hash.Hash {
+		var s [5]uint32
+		copy(s[:], state)
+		return sha1.NewFromState(s, n)
+	}},
+	// MD4 and MD5 use little-endian numbers rather than big-endian. See
+	// https://en.wikipedia.org/wiki/Comparison_of_cryptographic_hash_functions#Compression_function.
+	MD4: {"MD4", md4.Size, binary.LittleEndian, md4.New, func(state []uint32, n uint64) hash.Hash {
+		var s [4]uint32
+		copy(s[:], state)
+		return md4.NewFromState(s, n)
+	}},
+	SHA256: {"SHA-256", sha256.Size, binary.BigEndian, sha256.New, func(state []uint32, n uint64) hash.Hash {
+		var s [8]uint32
+		copy(s[:], state)
+		return sha256.NewFromState(s, n)
+	}},
+	MD5: {"MD5", md5.Size, binary.LittleEndian, md5.New, func(state []uint32, n uint64) hash.Hash {
+		var s [4]uint32
+		copy(s[:], state)
+		return md5.NewFromState(s, n)
+	}},
+}
+
+func (a HashAlg) String() string {
+	if info, ok := hashAlgs[a]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("HashAlg(%d)", int(a))
+}
+
+// Size returns the size of a's digests in bytes.
+func (a HashAlg) Size() int {
+	return hashAlgs[a].size
+}
+
+// New returns a new hash.Hash computing a's checksum.
+// It panics if a is unsupported.
+func (a HashAlg) New() hash.Hash {
+	info, ok := hashAlgs[a]
+	if !ok {
+		panic(fmt.Sprintf("unsupported hash algorithm %v", a))
+	}
+	return info.new()
+}
+
+// Sum returns a's checksum of data.
+func (a HashAlg) Sum(data []byte) []byte {
+	h := a.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// LengthExtend forges a secret-prefix MAC (i.e. H(key || msg)) using a length-extension attack.
+// origMAC is the MAC of an unknown message of origMsgLen bytes, including the secret key.
+// The returned mac is the MAC of the original message followed by pad and then suffix.
+//
+// A Merkle–Damgård hash's output is just its internal state after processing the padded
+// message, so a new hash can be initialized with that state and fed additional data. The
+// key's length is typically unknown, so callers should try each plausible value.
+func LengthExtend(alg HashAlg, origMAC []byte, origMsgLen int, suffix []byte) (mac, pad []byte, err error) {
+	info, ok := hashAlgs[alg]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported hash algorithm %v", alg)
+	}
+	if len(origMAC) != info.size {
+		return nil, nil, fmt.Errorf("%w (%v needs %v bytes; got %v)", ErrBadMACSize, alg, info.size, len(origMAC))
+	}
+	if origMsgLen < 0 {
+		return nil, nil, fmt.Errorf("negative message length %v", origMsgLen)
+	}
+
+	state := make([]uint32, info.size/4)
+	if err := binary.Read(bytes.NewReader(origMAC), info.bo, state); err != nil {
+		return nil, nil, err
+	}
+	pad = MDPadding(origMsgLen, info.bo)
+
+	// Resume hashing from the state that resulted after processing the padded original message.
+	h := info.fromState(state, uint64(origMsgLen+len(pad)))
+	h.Write(suffix)
+	return h.Sum(nil), pad, nil
+}
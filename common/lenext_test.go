@@ -0,0 +1,123 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	stdmd5 "crypto/md5"
+	stdsha1 "crypto/sha1"
+	stdsha256 "crypto/sha256"
+	"errors"
+	"hash"
+	"testing"
+
+	"github.com/derat/cryptopals/md4"
+	"github.com/derat/cryptopals/md5"
+	"github.com/derat/cryptopals/sha1"
+	"github.com/derat/cryptopals/sha256"
+)
+
+func TestLengthExtend(t *testing.T) {
+	const suffix = ";admin=true"
+	for _, tc := range []struct {
+		alg HashAlg
+		ref func() hash.Hash // independent implementation used to check results
+	}{
+		{SHA1, stdsha1.New},
+		{MD4, md4.New},
+		{SHA256, stdsha256.New},
+		{MD5, stdmd5.New},
+	} {
+		sum := func(b []byte) []byte {
+			h := tc.ref()
+			h.Write(b)
+			return h.Sum(nil)
+		}
+		if got, want := tc.alg.Sum([]byte("abc")), sum([]byte("abc")); !bytes.Equal(got, want) {
+			t.Errorf("%v Sum(%q) = %x; want %x", tc.alg, "abc", got, want)
+		}
+
+		// Try messages whose padding crosses block boundaries.
+		for _, n := range []int{0, 20, 55, 56, 64, 100, 119, 120} {
+			orig := append([]byte("secret"), A(n)...)
+			mac, pad, err := LengthExtend(tc.alg, sum(orig), len(orig), []byte(suffix))
+			if err != nil {
+				t.Errorf("%v LengthExtend with %v-byte message failed: %v", tc.alg, len(orig), err)
+				continue
+			}
+			msg := append(append(orig, pad...), suffix...)
+			if want := sum(msg); !bytes.Equal(mac, want) {
+				t.Errorf("%v LengthExtend with %v-byte message produced %x; want %x", tc.alg, len(orig), mac, want)
+			}
+		}
+
+		if _, _, err := LengthExtend(tc.alg, make([]byte, 3), 10, nil); !errors.Is(err, ErrBadMACSize) {
+			t.Errorf("%v LengthExtend with 3-byte MAC returned %v; want %v", tc.alg, err, ErrBadMACSize)
+		}
+	}
+
+	if _, _, err := LengthExtend(HashAlg(100), make([]byte, 20), 10, nil); err == nil {
+		t.Error("LengthExtend unexpectedly succeeded with unsupported algorithm")
+	}
+}
+
+func TestHashState(t *testing.T) {
+	for _, tc := range []struct {
+		alg HashAlg
+		// resume calls the package's State function on h and passes the result to NewFromState.
+		resume func(h hash.Hash) (r hash.Hash, processedLen uint64)
+		// setState calls the package's State function on src and passes the result to SetState.
+		setState func(dst, src hash.Hash)
+	}{
+		{
+			SHA1,
+			func(h hash.Hash) (hash.Hash, uint64) { s, n := sha1.State(h); return sha1.NewFromState(s, n), n },
+			func(dst, src hash.Hash) { s, _ := sha1.State(src); sha1.SetState(dst, s) },
+		},
+		{
+			MD4,
+			func(h hash.Hash) (hash.Hash, uint64) { s, n := md4.State(h); return md4.NewFromState(s, n), n },
+			func(dst, src hash.Hash) { s, _ := md4.State(src); md4.SetState(dst, s) },
+		},
+		{
+			SHA256,
+			func(h hash.Hash) (hash.Hash, uint64) { s, n := sha256.State(h); return sha256.NewFromState(s, n), n },
+			func(dst, src hash.Hash) { s, _ := sha256.State(src); sha256.SetState(dst, s) },
+		},
+		{
+			MD5,
+			func(h hash.Hash) (hash.Hash, uint64) { s, n := md5.State(h); return md5.NewFromState(s, n), n },
+			func(dst, src hash.Hash) { s, _ := md5.State(src); md5.SetState(dst, s) },
+		},
+	} {
+		data := bytes.Repeat([]byte("0123456789"), 20)
+		want := tc.alg.Sum(data)
+		bs := tc.alg.New().BlockSize()
+
+		for _, n := range []int{0, bs - 1, bs, 100, 2 * bs, len(data)} {
+			h := tc.alg.New()
+			h.Write(data[:n])
+			r, plen := tc.resume(h)
+			if exp := uint64(n - n%bs); plen != exp {
+				t.Errorf("%v State after writing %v bytes returned length %v; want %v", tc.alg, n, plen, exp)
+			}
+			r.Write(data[plen:])
+			if got := r.Sum(nil); !bytes.Equal(got, want) {
+				t.Errorf("%v resuming after %v bytes produced %x; want %x", tc.alg, n, got, want)
+			}
+		}
+
+		// SetState doesn't change the length, so write a block of junk first.
+		h := tc.alg.New()
+		h.Write(data[:bs])
+		r := tc.alg.New()
+		r.Write(make([]byte, bs))
+		tc.setState(r, h)
+		r.Write(data[bs:])
+		if got := r.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("%v resuming with SetState produced %x; want %x", tc.alg, got, want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"runtime"
+	"sync"
+
+	"github.com/derat/cryptopals/md4"
+)
+
+// MD4CollisionOptions configures FindMD4Collision.
+type MD4CollisionOptions struct {
+	// Prefix is hashed before the colliding blocks, which only collide when appended to it.
+	// Its length must be a multiple of md4.BlockSize.
+	Prefix []byte
+	// Workers is the number of goroutines used to search for collisions.
+	// If zero or negative, runtime.NumCPU is used.
+	Workers int
+}
+
+// FindMD4Collision uses Wang et al.'s differential attack from "Cryptanalysis of the Hash
+// Functions MD4 and RIPEMD" to find two different md4.BlockSize-byte blocks m1 and m2 such
+// that MD4(prefix || m1) == MD4(prefix || m2), where prefix is supplied via opts (which may
+// be nil). Since the blocks produce the same internal state, any suffix can be appended to both.
+// Random numbers are obtained from DefaultRand. ctx.Err() is returned if ctx is cancelled.
+//
+// The attack uses message words differing by Δm1 = 2^31, Δm2 = 2^31-2^28, and Δm12 = -2^16
+// and a set of sufficient conditions on intermediate state values that cause the differences
+// to cancel out. Conditions in the first round are satisfied by directly choosing the state
+// values and solving for the message words ("single-step modification"). Some conditions on
+// the first two state values of the second round are satisfied by changing first-round state
+// values and then adjusting the following message words to compensate ("multi-step
+// modification"). The remaining conditions hold by chance, but they're few enough that
+// a collision is usually found in well under a second.
+func FindMD4Collision(ctx context.Context, opts *MD4CollisionOptions) (m1, m2 []byte, err error) {
+	if opts == nil {
+		opts = &MD4CollisionOptions{}
+	}
+	if len(opts.Prefix)%md4.BlockSize != 0 {
+		return nil, nil, fmt.Errorf("%w (prefix is %v bytes)", ErrPartialBlock, len(opts.Prefix))
+	}
+	h := md4.New()
+	h.Write(opts.Prefix)
+	iv, _ := md4.State(h)
+
+	nw := opts.Workers
+	if nw <= 0 {
+		nw = runtime.NumCPU()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		found bool
+	)
+	for i := 0; i < nw; i++ {
+		wg.Add(1)
+		// Give each worker its own generator to avoid contention.
+		r := NewSeededRand(RandInt64(math.MaxInt64))
+		go func() {
+			defer wg.Done()
+			s := md4CollSearcher{iv: iv}
+			for ctx.Err() == nil {
+				if b1, b2, ok := s.try(r); ok {
+					once.Do(func() {
+						m1, m2, found = b1, b2, true
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !found {
+		return nil, nil, ctx.Err()
+	}
+	return m1, m2, nil
+}
+
+// md4CondKind describes a condition on a bit of an MD4 state value.
+type md4CondKind int
+
+const (
+	md4Zero   md4CondKind = iota // bit is 0
+	md4One                       // bit is 1
+	md4EqPrev                    // bit equals the same bit in the previous step's value
+	md4Eq2                       // bit equals the same bit in the value from two steps earlier
+	md4NePrev                    // bit differs from the same bit in the previous step's value
+)
+
+// md4Cond is a condition on a single bit of a state value.
+type md4Cond struct {
+	bit  uint // 1-indexed, as in the paper
+	kind md4CondKind
+}
+
+// md4Conds contains the sufficient conditions from Table 6 of Wang et al.'s paper,
+// indexed by step (i.e. a1, d1, c1, b1, a2, ..., c6). The conditions on b9 and a10 aren't
+// included since the final comparison of the hashes catches them.
+var md4Conds = [][]md4Cond{
+	// a1 (round 1)
+	{{7, md4EqPrev}},
+	// d1
+	{{7, md4Zero}, {8, md4EqPrev}, {11, md4EqPrev}},
+	// c1
+	{{7, md4One}, {8, md4One}, {11, md4Zero}, {26, md4EqPrev}},
+	// b1
+	{{7, md4One}, {8, md4Zero}, {11, md4Zero}, {26, md4Zero}},
+	// a2
+	{{8, md4One}, {11, md4One}, {26, md4Zero}, {14, md4EqPrev}},
+	// d2
+	{{14, md4Zero}, {19, md4EqPrev}, {20, md4EqPrev},
+		{21, md4EqPrev}, {22, md4EqPrev}, {26, md4One}},
+	// c2
+	{{13, md4EqPrev}, {14, md4Zero}, {15, md4EqPrev}, {19, md4Zero},
+		{20, md4Zero}, {21, md4One}, {22, md4Zero}},
+	// b2
+	{{13, md4One}, {14, md4One}, {15, md4Zero}, {17, md4EqPrev},
+		{19, md4Zero}, {20, md4Zero}, {21, md4Zero}, {22, md4Zero}},
+	// a3
+	{{13, md4One}, {14, md4One}, {15, md4One}, {17, md4Zero}, {19, md4Zero},
+		{20, md4Zero}, {21, md4Zero}, {22, md4One}, {23, md4EqPrev}, {26, md4EqPrev}},
+	// d3
+	{{13, md4One}, {14, md4One}, {15, md4One}, {17, md4Zero}, {20, md4Zero},
+		{21, md4One}, {22, md4One}, {23, md4Zero}, {26, md4One}, {30, md4EqPrev}},
+	// c3
+	{{17, md4One}, {20, md4Zero}, {21, md4Zero}, {22, md4Zero},
+		{23, md4Zero}, {26, md4Zero}, {30, md4One}, {32, md4EqPrev}},
+	// b3
+	{{20, md4Zero}, {21, md4One}, {22, md4One}, {23, md4EqPrev},
+		{26, md4One}, {30, md4Zero}, {32, md4Zero}},
+	// a4
+	{{23, md4Zero}, {26, md4Zero}, {27, md4EqPrev}, {29, md4EqPrev}, {30, md4One}, {32, md4Zero}},
+	// d4
+	{{23, md4Zero}, {26, md4Zero}, {27, md4One}, {29, md4One}, {30, md4Zero}, {32, md4One}},
+	// c4
+	{{19, md4EqPrev}, {23, md4One}, {26, md4One}, {27, md4Zero}, {29, md4Zero}, {30, md4Zero}},
+	// b4
+	{{19, md4Zero}, {26, md4EqPrev}, {27, md4One}, {29, md4One}, {30, md4Zero}},
+	// a5 (round 2)
+	{{19, md4Eq2}, {26, md4One}, {27, md4Zero}, {29, md4One}, {32, md4One}},
+	// d5
+	{{19, md4EqPrev}, {26, md4Eq2}, {27, md4Eq2}, {29, md4Eq2}, {32, md4Eq2}},
+	// c5
+	{{26, md4EqPrev}, {27, md4EqPrev}, {29, md4EqPrev}, {30, md4EqPrev}, {32, md4EqPrev}},
+	// b5
+	{{29, md4EqPrev}, {30, md4One}, {32, md4Zero}},
+	// a6
+	{{29, md4One}, {32, md4One}},
+	// d6
+	{{29, md4Eq2}},
+	// c6
+	{{29, md4EqPrev}, {30, md4NePrev}, {32, md4NePrev}},
+}
+
+// Per-step rotation amounts and message word indexes for MD4's first two rounds.
+var (
+	md4Shift1 = [4]int{3, 7, 11, 19}
+	md4Shift2 = [4]int{3, 5, 9, 13}
+	md4Index2 = [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}
+)
+
+// md4CollDiffs contains the differences between the colliding messages' words.
+var md4CollDiffs = [16]uint32{
+	1:  1 << 31,
+	2:  1<<31 - 1<<28,
+	12: 0xffff0000, // -2^16
+}
+
+// md4CollSearcher generates candidate messages for FindMD4Collision.
+type md4CollSearcher struct {
+	iv [4]uint32
+	m  [16]uint32
+	// q holds the initial state values in step order (a0, d0, c0, b0) followed by the value
+	// produced by each step, so the value produced by step i is at q[i+4].
+	q [4 + 23]uint32
+}
+
+// fix returns v, the value produced by step i, with the step's conditions applied.
+func (s *md4CollSearcher) fix(i int, v uint32) uint32 {
+	for _, c := range md4Conds[i] {
+		b := uint32(1) << (c.bit - 1)
+		switch c.kind {
+		case md4Zero:
+			v &^= b
+		case md4One:
+			v |= b
+		case md4EqPrev:
+			v ^= (v ^ s.q[i+3]) & b
+		case md4Eq2:
+			v ^= (v ^ s.q[i+2]) & b
+		case md4NePrev:
+			v ^= (v ^ ^s.q[i+3]) & b
+		}
+	}
+	return v
+}
+
+// step1 returns the value produced by first-round step i.
+func (s *md4CollSearcher) step1(i int) uint32 {
+	q := s.q[i:]
+	f := (q[3] & q[2]) | (^q[3] & q[1])
+	return bits.RotateLeft32(q[0]+f+s.m[i], md4Shift1[i%4])
+}
+
+// solve1 sets the message word used by first-round step i so the step produces s.q[i+4].
+func (s *md4CollSearcher) solve1(i int) {
+	q := s.q[i:]
+	f := (q[3] & q[2]) | (^q[3] & q[1])
+	s.m[i] = bits.RotateLeft32(q[4], -md4Shift1[i%4]) - q[0] - f
+}
+
+// step2 returns the value produced by second-round step i (counting from the start of the
+// first round).
+func (s *md4CollSearcher) step2(i int) uint32 {
+	q := s.q[i:]
+	g := (q[3] & q[2]) | (q[3] & q[1]) | (q[2] & q[1])
+	return bits.RotateLeft32(q[0]+g+s.m[md4Index2[i-16]]+0x5a827999, md4Shift2[i%4])
+}
+
+// modify2 tries to make second-round step i's value (currently v) satisfy its conditions
+// by changing the first-round value that used the same message word.
+func (s *md4CollSearcher) modify2(i int, v uint32) {
+	diff := v ^ s.fix(i, v)
+	if diff == 0 {
+		return
+	}
+	// Flipping bit j of the first-round value changes the message word by ±2^(j-s1),
+	// which (absent carries) flips bit j-s1+s2 of the second-round value.
+	w := md4Index2[i-16]
+	s.q[w+4] ^= bits.RotateLeft32(diff, md4Shift1[w%4]-md4Shift2[i%4])
+	// Update the message words so that the first-round values after the changed one
+	// are unaffected. The changed value is an input to the following four steps.
+	for j := w; j <= w+4 && j < 16; j++ {
+		s.solve1(j)
+	}
+}
+
+// try generates a random message using r and returns it and its counterpart if they collide.
+func (s *md4CollSearcher) try(r *Rand) (m1, m2 []byte, ok bool) {
+	b := r.Bytes(md4.BlockSize)
+	for i := range s.m {
+		s.m[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	s.q[0], s.q[1], s.q[2], s.q[3] = s.iv[0], s.iv[3], s.iv[2], s.iv[1]
+
+	// Single-step modification for the first round.
+	for i := 0; i < 16; i++ {
+		s.q[i+4] = s.fix(i, s.step1(i))
+		s.solve1(i)
+	}
+
+	// Multi-step modification for a5 and d5.
+	for i := 16; i < 18; i++ {
+		s.modify2(i, s.step2(i))
+		s.q[i+4] = s.step2(i)
+	}
+
+	// Give up early if any of the second-round conditions don't hold.
+	for i := 16; i < len(md4Conds); i++ {
+		if i >= 18 {
+			s.q[i+4] = s.step2(i)
+		}
+		if v := s.q[i+4]; s.fix(i, v) != v {
+			return nil, nil, false
+		}
+	}
+
+	m1 = make([]byte, md4.BlockSize)
+	m2 = make([]byte, md4.BlockSize)
+	for i, w := range s.m {
+		binary.LittleEndian.PutUint32(m1[i*4:], w)
+		binary.LittleEndian.PutUint32(m2[i*4:], w+md4CollDiffs[i])
+	}
+	if md4.Compress(s.iv, m1) != md4.Compress(s.iv, m2) {
+		return nil, nil, false
+	}
+	return m1, m2, true
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/derat/cryptopals/md4"
+)
+
+func TestFindMD4Collision(t *testing.T) {
+	ctx := context.Background()
+	for _, prefix := range [][]byte{nil, A(2 * md4.BlockSize)} {
+		m1, m2, err := FindMD4Collision(ctx, &MD4CollisionOptions{Prefix: prefix})
+		if err != nil {
+			t.Errorf("FindMD4Collision with %v-byte prefix failed: %v", len(prefix), err)
+			continue
+		}
+		if len(m1) != md4.BlockSize || len(m2) != md4.BlockSize {
+			t.Errorf("FindMD4Collision with %v-byte prefix returned %v- and %v-byte messages",
+				len(prefix), len(m1), len(m2))
+		}
+		if bytes.Equal(m1, m2) {
+			t.Errorf("FindMD4Collision with %v-byte prefix returned identical messages %x", len(prefix), m1)
+		}
+		// The collision should survive appending a suffix.
+		for _, suffix := range []string{"", "some suffix"} {
+			full1 := append(append(append([]byte{}, prefix...), m1...), suffix...)
+			full2 := append(append(append([]byte{}, prefix...), m2...), suffix...)
+			if s1, s2 := md4.Sum(full1), md4.Sum(full2); s1 != s2 {
+				t.Errorf("MD4 of %x is %x but MD4 of %x is %x", full1, s1, full2, s2)
+			}
+		}
+	}
+
+	if _, _, err := FindMD4Collision(ctx, &MD4CollisionOptions{Prefix: A(10)}); !errors.Is(err, ErrPartialBlock) {
+		t.Errorf("FindMD4Collision with 10-byte prefix returned %v; want %v", err, ErrPartialBlock)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := FindMD4Collision(cctx, nil); err != context.Canceled {
+		t.Errorf("FindMD4Collision with cancelled context returned %v; want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"io"
+)
+
+// ecb implements cipher.BlockMode for electronic codebook mode.
+// Each block is encrypted or decrypted independently.
+type ecb struct {
+	b       cipher.Block
+	bs      int
+	encrypt bool
+}
+
+// NewECBEncrypter returns a cipher.BlockMode that encrypts using b in ECB mode.
+func NewECBEncrypter(b cipher.Block) cipher.BlockMode {
+	return &ecb{b: b, bs: b.BlockSize(), encrypt: true}
+}
+
+// NewECBDecrypter returns a cipher.BlockMode that decrypts using b in ECB mode.
+func NewECBDecrypter(b cipher.Block) cipher.BlockMode {
+	return &ecb{b: b, bs: b.BlockSize(), encrypt: false}
+}
+
+func (m *ecb) BlockSize() int { return m.bs }
+
+func (m *ecb) CryptBlocks(dst, src []byte) {
+	checkBlocks(m.bs, dst, src)
+	for len(src) > 0 {
+		if m.encrypt {
+			m.b.Encrypt(dst, src[:m.bs])
+		} else {
+			m.b.Decrypt(dst, src[:m.bs])
+		}
+		src = src[m.bs:]
+		dst = dst[m.bs:]
+	}
+}
+
+// cbcEncrypter implements cipher.BlockMode for encryption in cipher block chaining mode.
+type cbcEncrypter struct {
+	b    cipher.Block
+	bs   int
+	prev []byte // previous ciphertext block (initially the IV)
+}
+
+// NewCBCEncrypter returns a cipher.BlockMode that encrypts using b in CBC mode.
+// The length of iv must match b's block size. iv is copied.
+func NewCBCEncrypter(b cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	if len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("%w: got %v; need %v", ErrBadIVSize, len(iv), b.BlockSize())
+	}
+	return &cbcEncrypter{b: b, bs: b.BlockSize(), prev: append([]byte{}, iv...)}, nil
+}
+
+func (m *cbcEncrypter) BlockSize() int { return m.bs }
+
+func (m *cbcEncrypter) CryptBlocks(dst, src []byte) {
+	checkBlocks(m.bs, dst, src)
+	for len(src) > 0 {
+		// XOR the plaintext block with the previous ciphertext block and encrypt it in place.
+		for i := 0; i < m.bs; i++ {
+			dst[i] = src[i] ^ m.prev[i]
+		}
+		m.b.Encrypt(dst[:m.bs], dst[:m.bs])
+		copy(m.prev, dst[:m.bs])
+		src = src[m.bs:]
+		dst = dst[m.bs:]
+	}
+}
+
+// cbcDecrypter implements cipher.BlockMode for decryption in cipher block chaining mode.
+type cbcDecrypter struct {
+	b    cipher.Block
+	bs   int
+	prev []byte // previous ciphertext block (initially the IV)
+	tmp  []byte // current ciphertext block, saved in case dst and src overlap
+}
+
+// NewCBCDecrypter returns a cipher.BlockMode that decrypts using b in CBC mode.
+// The length of iv must match b's block size. iv is copied.
+func NewCBCDecrypter(b cipher.Block, iv []byte) (cipher.BlockMode, error) {
+	if len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("%w: got %v; need %v", ErrBadIVSize, len(iv), b.BlockSize())
+	}
+	return &cbcDecrypter{
+		b:    b,
+		bs:   b.BlockSize(),
+		prev: append([]byte{}, iv...),
+		tmp:  make([]byte, b.BlockSize()),
+	}, nil
+}
+
+func (m *cbcDecrypter) BlockSize() int { return m.bs }
+
+func (m *cbcDecrypter) CryptBlocks(dst, src []byte) {
+	checkBlocks(m.bs, dst, src)
+	for len(src) > 0 {
+		copy(m.tmp, src[:m.bs])
+		m.b.Decrypt(dst[:m.bs], src[:m.bs])
+		for i := 0; i < m.bs; i++ {
+			dst[i] ^= m.prev[i]
+		}
+		m.prev, m.tmp = m.tmp, m.prev
+		src = src[m.bs:]
+		dst = dst[m.bs:]
+	}
+}
+
+// checkBlocks panics if src doesn't contain full blocks or if dst is too small.
+// Panicking matches the behavior documented for cipher.BlockMode.CryptBlocks.
+func checkBlocks(bs int, dst, src []byte) {
+	if len(src)%bs != 0 {
+		panic(fmt.Sprintf("buffer size %v isn't multiple of block size %v", len(src), bs))
+	}
+	if len(dst) < len(src) {
+		panic(fmt.Sprintf("output size %v is smaller than input size %v", len(dst), len(src)))
+	}
+}
+
+// CryptBlocks is a wrapper around m.CryptBlocks that returns an error
+// instead of panicking if src isn't a multiple of the block size.
+func CryptBlocks(m cipher.BlockMode, dst, src []byte) error {
+	if bs := m.BlockSize(); len(src)%bs != 0 {
+		return fmt.Errorf("%w: %v isn't multiple of %v", ErrPartialBlock, len(src), bs)
+	}
+	if len(dst) < len(src) {
+		return fmt.Errorf("%w: %v is smaller than input size %v", ErrShortOutput, len(dst), len(src))
+	}
+	m.CryptBlocks(dst, src)
+	return nil
+}
+
+// processBufBlocks is the number of blocks that ProcessBlocks reads at once.
+const processBufBlocks = 256
+
+// ProcessBlocks reads from r until EOF and writes data encrypted or decrypted by m to w.
+// A single buffer is reused, so arbitrarily-large inputs can be processed.
+// An error is returned if the total input length isn't a multiple of m's block size.
+func ProcessBlocks(m cipher.BlockMode, r io.Reader, w io.Writer) error {
+	bs := m.BlockSize()
+	b := make([]byte, processBufBlocks*bs)
+	var total int64
+	for {
+		n, rerr := io.ReadFull(r, b)
+		total += int64(n)
+		if rerr == io.ErrUnexpectedEOF && n%bs != 0 {
+			return fmt.Errorf("%w: input size %v isn't multiple of %v", ErrPartialBlock, total, bs)
+		}
+		if n > 0 {
+			m.CryptBlocks(b[:n], b[:n])
+			if _, err := w.Write(b[:n]); err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			return nil
+		} else if rerr != nil {
+			return rerr
+		}
+	}
+}
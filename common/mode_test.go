@@ -0,0 +1,127 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"errors"
+	"testing"
+)
+
+func TestCBC_Interop(t *testing.T) {
+	const (
+		key = "YELLOW SUBMARINE"
+		iv  = "1234567890123456"
+	)
+	b, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := PadPKCS7([]byte("This is the plaintext. It's more than a single block long."), 16)
+
+	want := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(b, []byte(iv)).CryptBlocks(want, plain)
+
+	enc, err := NewCBCEncrypter(b, []byte(iv))
+	if err != nil {
+		t.Fatal("NewCBCEncrypter failed: ", err)
+	}
+	got := make([]byte, len(plain))
+	enc.CryptBlocks(got, plain)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Encrypted to %x; want %x", got, want)
+	}
+
+	// Decrypt in place.
+	dec, err := NewCBCDecrypter(b, []byte(iv))
+	if err != nil {
+		t.Fatal("NewCBCDecrypter failed: ", err)
+	}
+	dec.CryptBlocks(got, got)
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("Decrypted to %q; want %q", got, plain)
+	}
+}
+
+func TestCBC_BadIV(t *testing.T) {
+	b, err := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCBCEncrypter(b, A(8)); err == nil {
+		t.Error("NewCBCEncrypter unexpectedly accepted 8-byte IV")
+	}
+	if _, err := NewCBCDecrypter(b, nil); err == nil {
+		t.Error("NewCBCDecrypter unexpectedly accepted nil IV")
+	}
+}
+
+func TestProcessBlocks(t *testing.T) {
+	// Use DES to check that block sizes other than 16 work.
+	b, err := des.NewCipher([]byte("8bytekey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := A(b.BlockSize())
+
+	// Use a buffer that's larger than ProcessBlocks's internal buffer.
+	plain := bytes.Repeat([]byte("0123456789abcdef"), processBufBlocks+3)
+
+	for _, tc := range []struct {
+		name     string
+		enc, dec func() cipher.BlockMode
+	}{
+		{
+			"ECB",
+			func() cipher.BlockMode { return NewECBEncrypter(b) },
+			func() cipher.BlockMode { return NewECBDecrypter(b) },
+		},
+		{
+			"CBC",
+			func() cipher.BlockMode { m, _ := NewCBCEncrypter(b, iv); return m },
+			func() cipher.BlockMode { m, _ := NewCBCDecrypter(b, iv); return m },
+		},
+	} {
+		var enc bytes.Buffer
+		if err := ProcessBlocks(tc.enc(), bytes.NewReader(plain), &enc); err != nil {
+			t.Errorf("%v encryption failed: %v", tc.name, err)
+			continue
+		}
+		// Streaming should produce the same output as a single call.
+		want := make([]byte, len(plain))
+		tc.enc().CryptBlocks(want, plain)
+		if !bytes.Equal(enc.Bytes(), want) {
+			t.Errorf("%v streamed encryption doesn't match single call", tc.name)
+		}
+
+		var dec bytes.Buffer
+		if err := ProcessBlocks(tc.dec(), &enc, &dec); err != nil {
+			t.Errorf("%v decryption failed: %v", tc.name, err)
+		} else if !bytes.Equal(dec.Bytes(), plain) {
+			t.Errorf("%v decrypted to %q; want %q", tc.name, dec.Bytes(), plain)
+		}
+
+		if err := ProcessBlocks(tc.enc(), bytes.NewReader(plain[:len(plain)-1]), &enc); err == nil {
+			t.Errorf("%v unexpectedly accepted partial block", tc.name)
+		}
+	}
+}
+
+func TestCryptBlocks_Invalid(t *testing.T) {
+	b, err := aes.NewCipher([]byte("YELLOW SUBMARINE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewECBEncrypter(b)
+	if err := CryptBlocks(m, make([]byte, 17), make([]byte, 17)); !errors.Is(err, ErrPartialBlock) {
+		t.Errorf("CryptBlocks with partial block returned %v; want %v", err, ErrPartialBlock)
+	}
+	if err := CryptBlocks(m, make([]byte, 16), make([]byte, 32)); !errors.Is(err, ErrShortOutput) {
+		t.Errorf("CryptBlocks with short output buffer returned %v; want %v", err, ErrShortOutput)
+	}
+}
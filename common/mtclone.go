@@ -0,0 +1,265 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// CloneMT returns an MT using params that will produce the same outputs as the generator
+// that produced outs, a sequence of at least params.N consecutive outputs.
+// The first output in outs may have been produced at any point in the generator's sequence.
+// If more than params.N outputs are supplied, the clone is checked against the remaining ones.
+// The returned MT's next output is the one that would follow the last element of outs.
+func CloneMT(params MTParams, outs []uint64) (*MT, error) {
+	if len(outs) < params.N {
+		return nil, fmt.Errorf("%w (need %v; got %v)", ErrNotEnoughOutputs, params.N, len(outs))
+	}
+
+	// The untempered outputs are consecutive values from the generator's state sequence.
+	// Twisting them produces the next values in the sequence regardless of where they
+	// started, so there's no need to know the original generator's index.
+	m := newMT(&params, 0)
+	for i := range m.mt {
+		m.mt[i] = m.Untemper(outs[i])
+	}
+	m.index = params.N
+
+	for i := params.N; i < len(outs); i++ {
+		if v := m.Extract(); v != outs[i] {
+			return nil, fmt.Errorf("%w (output %d is %v; clone produced %v)", ErrBadOutputs, i, outs[i], v)
+		}
+	}
+	return m, nil
+}
+
+// MTOutput describes an output from an MT whose bits may have only been partially observed.
+type MTOutput struct {
+	Index int    // position of the output in the generator's sequence, starting at 0
+	Value uint64 // output value; only bits in Mask are used
+	Mask  uint64 // bits of Value that were observed
+}
+
+// RecoverMT returns an MT using params that is in the same state as an unknown generator
+// before it produced outs, so that its first output will be the one with index 0.
+//
+// Unlike CloneMT, the outputs need not be consecutive and only some bits of each (e.g. the
+// top bits from a generator being used to produce small random numbers) need to be known.
+// Every output bit is a linear function over GF(2) of the bits of the generator's initial state,
+// so the state can be recovered by solving a system of linear equations. Enough bits must be
+// supplied to fully determine the state: W*N-R bits at the very least (19937 for MT19937),
+// and more in practice since some observations will be linearly dependent. If the observed
+// indexes follow a pattern with a period dividing N (e.g. every other output of MT19937),
+// some state values are never observed and ErrNotEnoughOutputs is returned.
+//
+// Outputs produced shortly after the initial state are cheap to solve for, but each twist
+// makes the equations denser, so recovering MT19937's state from outputs spread across
+// dozens of twists can take tens of seconds.
+func RecoverMT(params MTParams, outs []MTOutput) (*MT, error) {
+	m := newMT(&params, 0)
+	w, n := params.W, params.N
+	nv := w * n // number of unknown bits
+
+	// Find the contribution of each state bit to each output bit.
+	var temper [64]uint64 // bits of the state value that are XOR-ed to produce each output bit
+	for j := 0; j < w; j++ {
+		t := m.Temper(1 << j)
+		for b := 0; b < w; b++ {
+			if t&(1<<b) != 0 {
+				temper[b] |= 1 << j
+			}
+		}
+	}
+
+	sorted := append([]MTOutput{}, outs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+
+	st := newSymMTState(&params)
+	sys := newGF2System(nv)
+	gen := 0 // number of times that st has been twisted
+	for _, o := range sorted {
+		if o.Index < 0 {
+			return nil, fmt.Errorf("negative output index %v", o.Index)
+		}
+		for gen <= o.Index/n {
+			st.twist()
+			gen++
+		}
+		word := st.words[o.Index%n]
+		for b := 0; b < w; b++ {
+			if o.Mask&(1<<b) == 0 {
+				continue
+			}
+			row := sys.newRow()
+			for j := 0; j < w; j++ {
+				if temper[b]&(1<<j) != 0 {
+					row.xor(word[j])
+				}
+			}
+			if err := sys.add(row, o.Value&(1<<b) != 0); err != nil {
+				return nil, fmt.Errorf("output %d bit %d: %w", o.Index, b, err)
+			}
+		}
+	}
+
+	// The bottom R bits of the first state value are never used.
+	if need := nv - params.R; sys.rank() < need {
+		return nil, fmt.Errorf("%w (have %v independent bits; need %v)", ErrNotEnoughOutputs, sys.rank(), need)
+	}
+	sol := sys.solve()
+	for i := range m.mt {
+		m.mt[i] = 0
+		for b := 0; b < w; b++ {
+			if sol.get(i*w + b) {
+				m.mt[i] |= 1 << b
+			}
+		}
+	}
+	return m, nil
+}
+
+// symMTState symbolically represents an MT's state array.
+// Each bit is represented by the set of initial state bits that are XOR-ed to produce it.
+type symMTState struct {
+	p     *MTParams
+	words [][]bitVec // indexed by word and then by bit
+}
+
+func newSymMTState(p *MTParams) *symMTState {
+	st := &symMTState{p: p, words: make([][]bitVec, p.N)}
+	for i := range st.words {
+		st.words[i] = make([]bitVec, p.W)
+		for b := range st.words[i] {
+			st.words[i][b] = newBitVec(p.W * p.N)
+			st.words[i][b].set(i*p.W + b)
+		}
+	}
+	return st
+}
+
+// twist performs the same operation as MT.twist.
+func (st *symMTState) twist() {
+	p := st.p
+	for i, cur := range st.words {
+		next, mid := st.words[(i+1)%p.N], st.words[(i+p.M)%p.N]
+		// x is made up of the upper W-R bits of cur and the lower R bits of next.
+		x := func(b int) bitVec {
+			if b >= p.R {
+				return cur[b]
+			}
+			return next[b]
+		}
+		x0 := x(0)
+		// New vectors are allocated rather than updating cur's in place,
+		// since x's bits are still needed after the corresponding bits are replaced.
+		for b := 0; b < p.W; b++ {
+			v := make(bitVec, len(x0))
+			v.xor(mid[b])
+			if b+1 < p.W {
+				v.xor(x(b + 1))
+			}
+			if p.A&(1<<b) != 0 {
+				v.xor(x0)
+			}
+			cur[b] = v
+		}
+	}
+}
+
+// bitVec is a vector of bits over GF(2).
+type bitVec []uint64
+
+func newBitVec(n int) bitVec { return make(bitVec, (n+63)/64) }
+
+func (v bitVec) set(i int)      { v[i/64] |= 1 << (i % 64) }
+func (v bitVec) get(i int) bool { return v[i/64]&(1<<(i%64)) != 0 }
+
+// xor XORs o into v.
+func (v bitVec) xor(o bitVec) {
+	for i := range v {
+		v[i] ^= o[i]
+	}
+}
+
+// gf2System incrementally reduces a system of linear equations over GF(2).
+// Each stored row's lowest set bit is its pivot, and no two rows share a pivot.
+type gf2System struct {
+	nv    int
+	rows  []bitVec // indexed by pivot; nil if no row has that pivot
+	rhs   []bool   // right-hand side of each row in rows
+	nrows int
+	spare bitVec
+}
+
+func newGF2System(nv int) *gf2System {
+	return &gf2System{nv: nv, rows: make([]bitVec, nv), rhs: make([]bool, nv)}
+}
+
+// newRow returns an empty row that can be passed to add.
+func (s *gf2System) newRow() bitVec {
+	if s.spare != nil {
+		r := s.spare
+		s.spare = nil
+		for i := range r {
+			r[i] = 0
+		}
+		return r
+	}
+	return newBitVec(s.nv)
+}
+
+// add adds the equation "row · x = rhs" to the system.
+// ErrBadOutputs is returned if the equation contradicts the existing ones.
+func (s *gf2System) add(row bitVec, rhs bool) error {
+	for i := 0; i < len(row); i++ {
+		for row[i] != 0 {
+			p := i*64 + bits.TrailingZeros64(row[i])
+			prow := s.rows[p]
+			if prow == nil {
+				s.rows[p] = row
+				s.rhs[p] = rhs
+				s.nrows++
+				return nil
+			}
+			// The pivot row has no bits below p, so only XOR the remaining words.
+			for j := i; j < len(row); j++ {
+				row[j] ^= prow[j]
+			}
+			rhs = rhs != s.rhs[p]
+		}
+	}
+	s.spare = row // the row was redundant
+	if rhs {
+		return ErrBadOutputs
+	}
+	return nil
+}
+
+// rank returns the number of linearly-independent equations in the system.
+func (s *gf2System) rank() int { return s.nrows }
+
+// solve returns a solution to the system. Free variables are set to 0.
+func (s *gf2System) solve() bitVec {
+	sol := newBitVec(s.nv)
+	// Each row only contains bits at or above its pivot, so solve from the top down.
+	for p := s.nv - 1; p >= 0; p-- {
+		row := s.rows[p]
+		if row == nil {
+			continue
+		}
+		v := s.rhs[p]
+		for i := p / 64; i < len(row); i++ {
+			x := row[i] & sol[i]
+			v = v != (bits.OnesCount64(x)%2 == 1)
+		}
+		// The pivot bit itself is still unset in sol, so it didn't contribute above.
+		if v {
+			sol.set(p)
+		}
+	}
+	return sol
+}
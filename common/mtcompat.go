@@ -0,0 +1,253 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// NewMTFromArray returns a new MT seeded using key, as done by the init_by_array
+// (or init_by_array64) function in the reference implementation. Python's random
+// module and numpy (for array seeds) seed their generators this way.
+// params must be MT19937Params or MT19937_64Params.
+func NewMTFromArray(params MTParams, key []uint64) *MT {
+	var seed, f1, f2 uint64
+	switch params {
+	case MT19937Params:
+		seed, f1, f2 = 19650218, 1664525, 1566083941
+	case MT19937_64Params:
+		seed, f1, f2 = 19650218, 3935559000370003845, 2862933555777941757
+	default:
+		panic(fmt.Sprintf("init_by_array unsupported for %v-bit MT", params.W))
+	}
+	if len(key) == 0 {
+		panic("empty key")
+	}
+
+	m := NewMT(params, seed)
+	n, w, mask := params.N, params.W, params.WMask
+	mix := func(i int, f uint64) uint64 {
+		return m.mt[i] ^ ((m.mt[i-1] ^ (m.mt[i-1] >> (w - 2))) * f)
+	}
+
+	i, j := 1, 0
+	k := n
+	if len(key) > k {
+		k = len(key)
+	}
+	for ; k > 0; k-- {
+		m.mt[i] = (mix(i, f1) + key[j] + uint64(j)) & mask
+		i++
+		j++
+		if i >= n {
+			m.mt[0] = m.mt[n-1]
+			i = 1
+		}
+		if j >= len(key) {
+			j = 0
+		}
+	}
+	for k = n - 1; k > 0; k-- {
+		m.mt[i] = (mix(i, f2) - uint64(i)) & mask
+		i++
+		if i >= n {
+			m.mt[0] = m.mt[n-1]
+			i = 1
+		}
+	}
+	m.mt[0] = 1 << (w - 1) // MSB is 1, assuring non-zero initial array
+	return m
+}
+
+// uint32Words splits v into little-endian 32-bit words, omitting leading zero words.
+// At least one word is always returned.
+func uint32Words(v uint64) []uint64 {
+	if v>>32 == 0 {
+		return []uint64{v}
+	}
+	return []uint64{v & 0xffffffff, v >> 32}
+}
+
+// res53 returns a float64 in [0, 1) with 53 random bits using two outputs from m.
+// This matches genrand_res53 from the reference implementation.
+func res53(m *MT) float64 {
+	a, b := m.Extract()>>5, m.Extract()>>6
+	return (float64(a)*67108864 + float64(b)) / 9007199254740992
+}
+
+// PyRandom mimics Python 3's random.Random class, which uses MT19937.
+// An MT cloned from a Python program's outputs (e.g. using CloneMT or RecoverMT)
+// can be wrapped to predict values that the program will produce.
+type PyRandom struct {
+	MT *MT
+}
+
+// NewPyRandom returns a PyRandom in the same state as random.Random(seed).
+func NewPyRandom(seed uint64) *PyRandom {
+	return &PyRandom{NewMTFromArray(MT19937Params, uint32Words(seed))}
+}
+
+// Random returns a float64 in [0, 1), like random.random().
+func (r *PyRandom) Random() float64 {
+	return res53(r.MT)
+}
+
+// GetRandBits returns an integer with k random bits, like random.getrandbits(k).
+// k must be in [0, 64].
+func (r *PyRandom) GetRandBits(k int) uint64 {
+	if k < 0 || k > 64 {
+		panic(fmt.Sprintf("can't get %d bits", k))
+	}
+	// Python fills the result 32 bits at a time starting with the least-significant word,
+	// dropping the low bits of the final word if k isn't a multiple of 32.
+	var v uint64
+	for shift := 0; k > 0; shift += 32 {
+		w := r.MT.Extract()
+		if k < 32 {
+			w >>= 32 - k
+		}
+		v |= w << shift
+		k -= 32
+	}
+	return v
+}
+
+// RandBelow returns an integer in [0, n), like random.Random._randbelow(n).
+func (r *PyRandom) RandBelow(n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	k := bits.Len64(n)
+	v := r.GetRandBits(k)
+	for v >= n {
+		v = r.GetRandBits(k)
+	}
+	return v
+}
+
+// RandRange returns an integer in [start, stop), like random.randrange(start, stop).
+func (r *PyRandom) RandRange(start, stop int64) int64 {
+	if stop <= start {
+		panic(fmt.Sprintf("empty range [%d, %d)", start, stop))
+	}
+	return start + int64(r.RandBelow(uint64(stop-start)))
+}
+
+// RandInt returns an integer in [a, b], like random.randint(a, b).
+func (r *PyRandom) RandInt(a, b int64) int64 {
+	return r.RandRange(a, b+1)
+}
+
+// PHPMTRand mimics PHP's mt_rand function as implemented in PHP 7.1 and later.
+// Earlier versions (and MT_RAND_PHP mode) used an incorrect twist and different
+// range scaling, which aren't supported.
+type PHPMTRand struct {
+	MT *MT
+}
+
+// NewPHPMTRand returns a PHPMTRand in the state produced by mt_srand(seed).
+func NewPHPMTRand(seed uint32) *PHPMTRand {
+	return &PHPMTRand{NewMT19937(uint64(seed))}
+}
+
+// Rand returns a 31-bit integer, like mt_rand() called without arguments.
+func (r *PHPMTRand) Rand() uint32 {
+	return uint32(r.MT.Extract() >> 1)
+}
+
+// RandRange returns an integer in [min, max], like mt_rand(min, max).
+func (r *PHPMTRand) RandRange(min, max int64) int64 {
+	umax := uint64(max) - uint64(min)
+	if umax > math.MaxUint32 {
+		return int64(r.range64(umax) + uint64(min))
+	}
+	return int64(uint64(r.range32(uint32(umax))) + uint64(min))
+}
+
+// range32 matches rand_range32 from PHP's ext/standard/mt_rand.c.
+func (r *PHPMTRand) range32(umax uint32) uint32 {
+	result := uint32(r.MT.Extract())
+	if umax == math.MaxUint32 {
+		return result
+	}
+	umax++
+	if umax&(umax-1) != 0 { // not a power of 2
+		limit := math.MaxUint32 - (math.MaxUint32 % umax) - 1
+		for result > limit {
+			result = uint32(r.MT.Extract())
+		}
+	}
+	return result % umax
+}
+
+// range64 matches rand_range64 from PHP's ext/standard/mt_rand.c.
+func (r *PHPMTRand) range64(umax uint64) uint64 {
+	next := func() uint64 { return r.MT.Extract()<<32 | r.MT.Extract() }
+	result := next()
+	if umax == math.MaxUint64 {
+		return result
+	}
+	umax++
+	if umax&(umax-1) != 0 {
+		limit := math.MaxUint64 - (math.MaxUint64 % umax) - 1
+		for result > limit {
+			result = next()
+		}
+	}
+	return result % umax
+}
+
+// RubyRandom mimics Ruby's Random class, which uses MT19937.
+type RubyRandom struct {
+	MT *MT
+}
+
+// NewRubyRandom returns a RubyRandom in the same state as Random.new(seed).
+// Ruby passes seeds that fit in 32 bits to init_genrand and larger ones to init_by_array.
+func NewRubyRandom(seed uint64) *RubyRandom {
+	if key := uint32Words(seed); len(key) > 1 {
+		return &RubyRandom{NewMTFromArray(MT19937Params, key)}
+	}
+	return &RubyRandom{NewMT19937(seed)}
+}
+
+// Float returns a float64 in [0, 1), like Random#rand called without arguments.
+func (r *RubyRandom) Float() float64 {
+	return res53(r.MT)
+}
+
+// Int returns an integer in [0, n), like Random#rand(n). n must be positive.
+func (r *RubyRandom) Int(n uint64) uint64 {
+	if n == 0 {
+		panic("invalid argument 0")
+	}
+	return r.limited(n - 1)
+}
+
+// limited matches limited_rand from Ruby's random.c, returning an integer in [0, limit].
+// Random 32-bit words are masked and rejected if they exceed limit, starting with the
+// most-significant word.
+func (r *RubyRandom) limited(limit uint64) uint64 {
+	if limit == 0 {
+		return 0
+	}
+	mask := uint64(1)<<bits.Len64(limit) - 1
+	for {
+		var val uint64
+		ok := true
+		for i := 1; i >= 0 && ok; i-- {
+			if (mask>>(i*32))&0xffffffff != 0 {
+				val |= r.MT.Extract() << (i * 32)
+				val &= mask
+				ok = val <= limit
+			}
+		}
+		if ok {
+			return val
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestNewMTFromArray(t *testing.T) {
+	// Expected values come from mt19937ar.out and mt19937-64.out.txt, distributed
+	// alongside the reference implementations.
+	for _, tc := range []struct {
+		params MTParams
+		key    []uint64
+		exps   []uint64
+	}{
+		{
+			MT19937Params,
+			[]uint64{0x123, 0x234, 0x345, 0x456},
+			[]uint64{1067595299, 955945823, 477289528, 4107218783, 4228976476},
+		},
+		{
+			MT19937_64Params,
+			[]uint64{0x12345, 0x23456, 0x34567, 0x45678},
+			[]uint64{7266447313870364031, 4946485549665804864, 16945909448695747420,
+				16394063075524226720, 4873882236456199058},
+		},
+	} {
+		mt := NewMTFromArray(tc.params, tc.key)
+		for i, exp := range tc.exps {
+			if n := mt.Extract(); n != exp {
+				t.Errorf("%v-bit Extract() = %v at iteration %v; want %v", tc.params.W, n, i, exp)
+			}
+		}
+	}
+}
+
+func TestPyRandom(t *testing.T) {
+	// Expected values came from Python 3.11, e.g.:
+	//
+	//   r = random.Random(42)
+	//   print(r.random(), r.getrandbits(8), r.getrandbits(40), ...)
+	r := NewPyRandom(42)
+	if got, want := r.Random(), 0.6394267984578837; got != want {
+		t.Errorf("Random() = %v; want %v", got, want)
+	}
+	for _, tc := range []struct {
+		k    int
+		want uint64
+	}{{8, 6}, {40, 303832645883}, {64, 4117511471858006928}} {
+		if got := r.GetRandBits(tc.k); got != tc.want {
+			t.Errorf("GetRandBits(%d) = %v; want %v", tc.k, got, tc.want)
+		}
+	}
+	if got, want := r.RandInt(1, 100), int64(18); got != want {
+		t.Errorf("RandInt(1, 100) = %v; want %v", got, want)
+	}
+	if got, want := r.RandRange(0, 1e12), int64(114832269481); got != want {
+		t.Errorf("RandRange(0, 1e12) = %v; want %v", got, want)
+	}
+	if got, want := r.RandRange(-5, 5), int64(3); got != want {
+		t.Errorf("RandRange(-5, 5) = %v; want %v", got, want)
+	}
+
+	// Seeds that don't fit in 32 bits are split into multiple words.
+	r = NewPyRandom(1<<40 + 12345)
+	if got, want := r.Random(), 0.31036222241287226; got != want {
+		t.Errorf("Random() with 64-bit seed = %v; want %v", got, want)
+	}
+	if got, want := r.GetRandBits(32), uint64(493462063); got != want {
+		t.Errorf("GetRandBits(32) with 64-bit seed = %v; want %v", got, want)
+	}
+	if got, want := NewPyRandom(0).Random(), 0.8444218515250481; got != want {
+		t.Errorf("Random() with zero seed = %v; want %v", got, want)
+	}
+}
+
+func TestPHPMTRand(t *testing.T) {
+	// PHP 7.1+ prints these for "mt_srand(1); echo mt_rand(), mt_rand();".
+	r := NewPHPMTRand(1)
+	for i, want := range []uint32{895547922, 2141438069} {
+		if got := r.Rand(); got != want {
+			t.Errorf("Rand() = %v at iteration %v; want %v", got, i, want)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if v := r.RandRange(-10, 10); v < -10 || v > 10 {
+			t.Fatalf("RandRange(-10, 10) = %v", v)
+		}
+		if v := r.RandRange(0, 1<<40); v < 0 || v > 1<<40 {
+			t.Fatalf("RandRange(0, 1<<40) = %v", v)
+		}
+	}
+
+	// mt_rand() drops the low bit of each output, but the generator can still be
+	// recovered from the remaining bits and used to predict future outputs.
+	const seed = 0x5eed
+	r = NewPHPMTRand(seed)
+	outs := make([]MTOutput, 1300)
+	for i := range outs {
+		outs[i] = MTOutput{Index: i, Value: uint64(r.Rand()) << 1, Mask: 0xfffffffe}
+	}
+	mt, err := RecoverMT(MT19937Params, outs)
+	if err != nil {
+		t.Fatal("RecoverMT failed: ", err)
+	}
+	pred := &PHPMTRand{mt}
+	for range outs {
+		pred.Rand()
+	}
+	for i := 0; i < 10; i++ {
+		if got, want := pred.RandRange(1, 1000), r.RandRange(1, 1000); got != want {
+			t.Fatalf("Predicted RandRange(1, 1000) = %v; want %v", got, want)
+		}
+	}
+}
+
+func TestRubyRandom(t *testing.T) {
+	// Ruby's documentation gives "Random.new(1234).rand(100) # => 47".
+	if got, want := NewRubyRandom(1234).Int(100), uint64(47); got != want {
+		t.Errorf("Int(100) = %v; want %v", got, want)
+	}
+	// Ruby, like numpy, passes 32-bit seeds to init_genrand and uses genrand_res53 for floats,
+	// so "numpy.random.seed(42); numpy.random.rand()" produces the same value.
+	if got, want := NewRubyRandom(42).Float(), 0.3745401188473625; got != want {
+		t.Errorf("Float() = %v; want %v", got, want)
+	}
+	r := NewRubyRandom(1 << 40)
+	for i := 0; i < 1000; i++ {
+		if v := r.Int(1<<33 + 1); v > 1<<33 {
+			t.Fatalf("Int(1<<33 + 1) = %v", v)
+		}
+	}
+}
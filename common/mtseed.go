@@ -0,0 +1,171 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// MTSeedOptions configures BruteForceMTSeed.
+type MTSeedOptions struct {
+	// MaxOffset is the maximum number of outputs that the generator may have produced
+	// before the first supplied output. If zero, the first supplied output must be the
+	// generator's first output.
+	MaxOffset int
+	// Workers is the number of goroutines used to check seeds.
+	// If zero or negative, runtime.NumCPU is used.
+	Workers int
+}
+
+// seedChunk is the number of consecutive seeds claimed by a BruteForceMTSeed worker at a time.
+const seedChunk = 1 << 14
+
+// BruteForceMTSeed searches for a seed in [min, max] that causes a generator using params
+// to produce outs, a sequence of one or more consecutive outputs. This is useful when the
+// generator's first outputs aren't available (if they are, see MT.Seed).
+// The seed and the number of outputs that preceded outs[0] are returned. If multiple seeds
+// match, any of them may be returned. ErrSeedNotFound is returned if no seed matches, and
+// ctx.Err() is returned if ctx is cancelled. opts may be nil.
+//
+// When outs is close enough to the start of the sequence that it was produced by the first
+// twist and doesn't depend on values that twist updated in place (i.e. for offsets below
+// N-M), only the needed part of each seed's state array is computed.
+func BruteForceMTSeed(ctx context.Context, params MTParams, outs []uint64, min, max uint64,
+	opts *MTSeedOptions) (seed uint64, offset int, err error) {
+	if opts == nil {
+		opts = &MTSeedOptions{}
+	}
+	if len(outs) == 0 {
+		return 0, 0, fmt.Errorf("%w (need at least 1)", ErrNotEnoughOutputs)
+	}
+	if max > params.WMask {
+		max = params.WMask
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("%w (empty range [%v, %v])", ErrSeedNotFound, min, max)
+	}
+
+	nw := opts.Workers
+	if nw <= 0 {
+		nw = runtime.NumCPU()
+	}
+	nchunks := (max-min)/seedChunk + 1
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		next  uint64 // index of next chunk to check; accessed atomically
+		wg    sync.WaitGroup
+		once  sync.Once
+		found bool
+	)
+	for i := 0; i < nw; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := newMTSeedSearcher(&params, outs, opts.MaxOffset)
+			for ctx.Err() == nil {
+				ci := atomic.AddUint64(&next, 1) - 1
+				if ci >= nchunks {
+					return
+				}
+				start := min + ci*seedChunk
+				end := start + seedChunk - 1
+				if end > max || end < start { // check for overflow
+					end = max
+				}
+				for sd := start; ; sd++ {
+					if off, ok := s.check(sd); ok {
+						once.Do(func() {
+							seed, offset, found = sd, off, true
+							cancel()
+						})
+						return
+					}
+					if sd == end {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if found {
+		return seed, offset, nil
+	}
+	if ctx.Err() != nil {
+		// Our own cancel is only called after finding the seed, so this must be the caller's.
+		return 0, 0, ctx.Err()
+	}
+	return 0, 0, fmt.Errorf("%w in [%v, %v]", ErrSeedNotFound, min, max)
+}
+
+// mtSeedSearcher checks whether individual seeds produce a sequence of outputs.
+// It reuses its buffers across seeds and isn't safe for concurrent use.
+type mtSeedSearcher struct {
+	m     *MT
+	outs  []uint64
+	gen   []uint64 // outputs generated by the current seed
+	state []uint64 // partial initial state for the fast path, or nil
+}
+
+func newMTSeedSearcher(p *MTParams, outs []uint64, maxOffset int) *mtSeedSearcher {
+	s := &mtSeedSearcher{
+		m:    newMT(p, 0),
+		outs: outs,
+		gen:  make([]uint64, maxOffset+len(outs)),
+	}
+	if len(s.gen) <= p.N-p.M {
+		// Output i is produced from initial values i, i+1, and i+M.
+		s.state = make([]uint64, len(s.gen)+p.M)
+	}
+	return s
+}
+
+// check returns true and the offset of s.outs within seed's outputs if seed produces them.
+func (s *mtSeedSearcher) check(seed uint64) (offset int, ok bool) {
+	m := s.m
+	p := m.params
+	if s.state != nil {
+		st := s.state
+		st[0] = seed
+		for i := 1; i < len(st); i++ {
+			st[i] = p.WMask & (p.F*(st[i-1]^(st[i-1]>>(p.W-2))) + uint64(i))
+		}
+		for i := range s.gen {
+			x := (st[i] & m.umask) + (st[i+1] & m.lmask)
+			xa := x >> 1
+			if x%2 != 0 {
+				xa ^= p.A
+			}
+			s.gen[i] = m.Temper(st[i+p.M] ^ xa)
+		}
+	} else {
+		m.reseed(seed)
+		for i := range s.gen {
+			s.gen[i] = m.Extract()
+		}
+	}
+
+	for off := 0; off+len(s.outs) <= len(s.gen); off++ {
+		if s.gen[off] != s.outs[0] {
+			continue
+		}
+		match := true
+		for i := 1; i < len(s.outs) && match; i++ {
+			match = s.gen[off+i] == s.outs[i]
+		}
+		if match {
+			return off, true
+		}
+	}
+	return 0, false
+}
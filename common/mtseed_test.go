@@ -0,0 +1,57 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBruteForceMTSeed(t *testing.T) {
+	const (
+		seed = 1600000123
+		min  = seed - 50000
+		max  = seed + 50000
+	)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		skip, num, maxOffset int
+	}{
+		{0, 1, 0},     // first output
+		{100, 2, 200}, // fast path
+		{700, 3, 800}, // requires full twist
+	} {
+		mt := NewMT19937(seed)
+		for i := 0; i < tc.skip; i++ {
+			mt.Extract()
+		}
+		outs := make([]uint64, tc.num)
+		for i := range outs {
+			outs[i] = mt.Extract()
+		}
+		opts := MTSeedOptions{MaxOffset: tc.maxOffset}
+		if tc.skip > MT19937Params.N {
+			opts.Workers = 2 // the full twist is slow, so don't start more workers than needed
+		}
+		got, off, err := BruteForceMTSeed(ctx, MT19937Params, outs, min, max, &opts)
+		if err != nil {
+			t.Errorf("BruteForceMTSeed with %v skipped output(s) failed: %v", tc.skip, err)
+		} else if got != seed || off != tc.skip {
+			t.Errorf("BruteForceMTSeed with %v skipped output(s) returned seed %v at %v; want %v at %v",
+				tc.skip, got, off, seed, tc.skip)
+		}
+	}
+
+	outs := []uint64{NewMT19937(seed).Extract()}
+	if _, _, err := BruteForceMTSeed(ctx, MT19937Params, outs, seed+1, max, nil); !errors.Is(err, ErrSeedNotFound) {
+		t.Errorf("BruteForceMTSeed with wrong range returned %v; want %v", err, ErrSeedNotFound)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := BruteForceMTSeed(cctx, MT19937Params, outs, 0, 1<<32-1, nil); err != context.Canceled {
+		t.Errorf("BruteForceMTSeed with cancelled context returned %v; want %v", err, context.Canceled)
+	}
+}
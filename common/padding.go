@@ -0,0 +1,210 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Padding describes a scheme for padding data to a multiple of a block size.
+type Padding interface {
+	// Pad returns a new buffer containing b padded to a multiple of bs.
+	Pad(b []byte, bs int) []byte
+	// Unpad returns b with its padding removed. b must be a multiple of bs.
+	// If the padding is invalid, an error wrapping ErrBadPadding is returned.
+	Unpad(b []byte, bs int) ([]byte, error)
+}
+
+// Supported padding schemes.
+var (
+	// PKCS7Padding appends n bytes with value n, as in PadPKCS7 and UnpadPKCS7.
+	PKCS7Padding Padding = pkcs7Padding{}
+	// X923Padding appends n-1 zero bytes followed by a byte with value n, as in ANSI X9.23.
+	X923Padding Padding = x923Padding{}
+	// ISO7816Padding appends a 0x80 byte followed by n-1 zero bytes, as in ISO/IEC 7816-4
+	// (and ISO/IEC 9797-1 padding method 2).
+	ISO7816Padding Padding = iso7816Padding{}
+	// ISO10126Padding appends n-1 random bytes followed by a byte with value n, as in ISO 10126.
+	ISO10126Padding Padding = iso10126Padding{}
+	// ZeroPadding appends zero bytes until the data is a multiple of the block size.
+	// Unlike the other schemes, no padding is added to data that's already a multiple of the
+	// block size, and trailing zero bytes in the original data are lost when unpadding.
+	ZeroPadding Padding = zeroPadding{}
+)
+
+// padLen returns the number of bytes that need to be appended to an n-byte buffer
+// to reach the next multiple of bs. A full block is added if n is already a multiple.
+func padLen(n, bs int) int {
+	return bs - n%bs
+}
+
+// padWith returns a copy of b with the supplied padding appended.
+func padWith(b, pad []byte) []byte {
+	padded := make([]byte, len(b)+len(pad))
+	copy(padded[copy(padded, b):], pad)
+	return padded
+}
+
+// checkUnpadLen returns an error if b isn't a non-empty multiple of bs.
+func checkUnpadLen(b []byte, bs int) error {
+	if len(b) == 0 || len(b)%bs != 0 {
+		return fmt.Errorf("%w: %v-byte buffer with block size %v", ErrBadPadding, len(b), bs)
+	}
+	return nil
+}
+
+// countPad returns the final byte of b (which must be non-empty) as a padding length,
+// or an error if it isn't in the range [1, bs].
+func countPad(b []byte, bs int) (int, error) {
+	np := int(b[len(b)-1])
+	if np == 0 || np > bs {
+		return 0, fmt.Errorf("%w: %v byte(s) of padding with block size %v", ErrBadPadding, np, bs)
+	}
+	return np, nil
+}
+
+type pkcs7Padding struct{}
+
+func (pkcs7Padding) String() string { return "PKCS#7" }
+
+func (pkcs7Padding) Pad(b []byte, bs int) []byte { return PadPKCS7(b, bs) }
+
+func (pkcs7Padding) Unpad(b []byte, bs int) ([]byte, error) {
+	if err := checkUnpadLen(b, bs); err != nil {
+		return nil, err
+	}
+	if _, err := countPad(b, bs); err != nil {
+		return nil, err
+	}
+	return UnpadPKCS7(b)
+}
+
+type x923Padding struct{}
+
+func (x923Padding) String() string { return "ANSI X9.23" }
+
+func (x923Padding) Pad(b []byte, bs int) []byte {
+	pad := make([]byte, padLen(len(b), bs))
+	pad[len(pad)-1] = byte(len(pad))
+	return padWith(b, pad)
+}
+
+func (x923Padding) Unpad(b []byte, bs int) ([]byte, error) {
+	if err := checkUnpadLen(b, bs); err != nil {
+		return nil, err
+	}
+	np, err := countPad(b, bs)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(b) - np; i < len(b)-1; i++ {
+		if b[i] != 0 {
+			return nil, fmt.Errorf("%w: %v byte(s) of padding but byte %d is %v", ErrBadPadding, np, i, b[i])
+		}
+	}
+	return b[:len(b)-np], nil
+}
+
+type iso7816Padding struct{}
+
+func (iso7816Padding) String() string { return "ISO/IEC 7816-4" }
+
+func (iso7816Padding) Pad(b []byte, bs int) []byte {
+	pad := make([]byte, padLen(len(b), bs))
+	pad[0] = 0x80
+	return padWith(b, pad)
+}
+
+func (iso7816Padding) Unpad(b []byte, bs int) ([]byte, error) {
+	if err := checkUnpadLen(b, bs); err != nil {
+		return nil, err
+	}
+	// Skip zero bytes within the final block and then look for the 0x80 marker.
+	for i := len(b) - 1; i >= len(b)-bs; i-- {
+		switch b[i] {
+		case 0:
+			continue
+		case 0x80:
+			return b[:i], nil
+		default:
+			return nil, fmt.Errorf("%w: byte %d is %v; want 0x80 or 0", ErrBadPadding, i, b[i])
+		}
+	}
+	return nil, fmt.Errorf("%w: no 0x80 byte in final block", ErrBadPadding)
+}
+
+type iso10126Padding struct{}
+
+func (iso10126Padding) String() string { return "ISO 10126" }
+
+func (iso10126Padding) Pad(b []byte, bs int) []byte {
+	pad := RandBytes(padLen(len(b), bs))
+	pad[len(pad)-1] = byte(len(pad))
+	return padWith(b, pad)
+}
+
+func (iso10126Padding) Unpad(b []byte, bs int) ([]byte, error) {
+	if err := checkUnpadLen(b, bs); err != nil {
+		return nil, err
+	}
+	// The other padding bytes are random, so only the length can be checked.
+	np, err := countPad(b, bs)
+	if err != nil {
+		return nil, err
+	}
+	return b[:len(b)-np], nil
+}
+
+type zeroPadding struct{}
+
+func (zeroPadding) String() string { return "zero" }
+
+func (zeroPadding) Pad(b []byte, bs int) []byte {
+	return padWith(b, make([]byte, padLen(len(b), bs)%bs))
+}
+
+func (zeroPadding) Unpad(b []byte, bs int) ([]byte, error) {
+	// Empty buffers are valid since nothing is added to data that's already a multiple of bs.
+	if len(b)%bs != 0 {
+		return nil, fmt.Errorf("%w: %v-byte buffer with block size %v", ErrBadPadding, len(b), bs)
+	}
+	// Only trailing zeros in the final block can be padding.
+	end := len(b)
+	for end > 0 && end > len(b)-bs && b[end-1] == 0 {
+		end--
+	}
+	return b[:end], nil
+}
+
+// oraclePadding is implemented by Padding schemes that can be attacked using a padding oracle.
+// See decryptBlockCBC.
+type oraclePadding interface {
+	// oracleTail returns the final n bytes of a block (where 1 <= n <= bs) that produce valid
+	// padding only if the first of those bytes has the returned value, given that the remaining
+	// bytes also have the returned values. If confirm is true, a block with valid padding may
+	// also be produced by other values of the first byte in combination with specific values
+	// of earlier bytes, so validity must be confirmed after modifying the preceding byte.
+	oracleTail(n int) (tail []byte, confirm bool)
+}
+
+func (pkcs7Padding) oracleTail(n int) ([]byte, bool) {
+	// Only the final byte is ambiguous: 0x2 is also valid if it's preceded by 0x2, etc.
+	return bytes.Repeat([]byte{byte(n)}, n), n == 1
+}
+
+func (x923Padding) oracleTail(n int) ([]byte, bool) {
+	// As with PKCS#7, 0x2 in the final byte is also valid if it's preceded by 0x0, etc.
+	tail := make([]byte, n)
+	tail[n-1] = byte(n)
+	return tail, n == 1
+}
+
+func (iso7816Padding) oracleTail(n int) ([]byte, bool) {
+	// The first byte can always also be 0x0 if it's preceded by 0x80 (and possibly more zeros).
+	tail := make([]byte, n)
+	tail[0] = 0x80
+	return tail, true
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPaddings(t *testing.T) {
+	for _, tc := range []struct {
+		p        Padding
+		unpadded string
+		bs       int
+		padded   string
+	}{
+		{PKCS7Padding, "", 4, "\x04\x04\x04\x04"},
+		{PKCS7Padding, "123", 4, "123\x01"},
+		{PKCS7Padding, "1234", 4, "1234\x04\x04\x04\x04"},
+		{X923Padding, "", 4, "\x00\x00\x00\x04"},
+		{X923Padding, "1", 4, "1\x00\x00\x03"},
+		{X923Padding, "123", 4, "123\x01"},
+		{X923Padding, "1234", 4, "1234\x00\x00\x00\x04"},
+		{ISO7816Padding, "", 4, "\x80\x00\x00\x00"},
+		{ISO7816Padding, "1", 4, "1\x80\x00\x00"},
+		{ISO7816Padding, "123", 4, "123\x80"},
+		{ISO7816Padding, "1234", 4, "1234\x80\x00\x00\x00"},
+		{ISO7816Padding, "12\x80", 4, "12\x80\x80"},
+		{ZeroPadding, "", 4, ""},
+		{ZeroPadding, "1", 4, "1\x00\x00\x00"},
+		{ZeroPadding, "123", 4, "123\x00"},
+		{ZeroPadding, "1234", 4, "1234"},
+	} {
+		if padded := tc.p.Pad([]byte(tc.unpadded), tc.bs); !bytes.Equal(padded, []byte(tc.padded)) {
+			t.Errorf("%v Pad(%q, %d) = %q; want %q", tc.p, tc.unpadded, tc.bs, padded, tc.padded)
+		}
+		if unpadded, err := tc.p.Unpad([]byte(tc.padded), tc.bs); err != nil {
+			t.Errorf("%v Unpad(%q, %d) failed: %v", tc.p, tc.padded, tc.bs, err)
+		} else if !bytes.Equal(unpadded, []byte(tc.unpadded)) {
+			t.Errorf("%v Unpad(%q, %d) = %q; want %q", tc.p, tc.padded, tc.bs, unpadded, tc.unpadded)
+		}
+	}
+}
+
+func TestISO10126Padding(t *testing.T) {
+	// The padding is random, so just check its length and final byte.
+	const bs = 8
+	for _, s := range []string{"", "1", "1234567", "12345678"} {
+		padded := ISO10126Padding.Pad([]byte(s), bs)
+		np := bs - len(s)%bs
+		if len(padded) != len(s)+np || !bytes.HasPrefix(padded, []byte(s)) || padded[len(padded)-1] != byte(np) {
+			t.Errorf("ISO10126Padding.Pad(%q, %d) = %q", s, bs, padded)
+		}
+		if unpadded, err := ISO10126Padding.Unpad(padded, bs); err != nil {
+			t.Errorf("ISO10126Padding.Unpad(%q, %d) failed: %v", padded, bs, err)
+		} else if !bytes.Equal(unpadded, []byte(s)) {
+			t.Errorf("ISO10126Padding.Unpad(%q, %d) = %q; want %q", padded, bs, unpadded, s)
+		}
+	}
+}
+
+func TestPaddings_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		p      Padding
+		padded string
+		bs     int
+	}{
+		{PKCS7Padding, "", 4},
+		{PKCS7Padding, "12345", 4},
+		{PKCS7Padding, "123\x00", 4},
+		{PKCS7Padding, "12\x01\x02", 4},
+		{PKCS7Padding, "1234\x05\x05\x05\x05", 4}, // more padding than block size
+		{X923Padding, "", 4},
+		{X923Padding, "123\x00", 4},
+		{X923Padding, "1\x00\x01\x03", 4},
+		{X923Padding, "1234\x00\x00\x00\x05", 4},
+		{ISO7816Padding, "", 4},
+		{ISO7816Padding, "1234", 4},
+		{ISO7816Padding, "\x80\x00\x00\x00\x00\x00\x00\x00", 4}, // marker isn't in final block
+		{ISO7816Padding, "1\x80\x01\x00", 4},
+		{ISO10126Padding, "", 4},
+		{ISO10126Padding, "123\x00", 4},
+		{ISO10126Padding, "123\x05", 4},
+		{ZeroPadding, "123", 4},
+	} {
+		if _, err := tc.p.Unpad([]byte(tc.padded), tc.bs); !errors.Is(err, ErrBadPadding) {
+			t.Errorf("%v Unpad(%q, %d) returned %v; want %v", tc.p, tc.padded, tc.bs, err, ErrBadPadding)
+		}
+	}
+}
@@ -16,6 +16,11 @@ type MT struct {
 	params *MTParams
 }
 
+// NewMT returns a new MT using the supplied parameters (e.g. MT19937Params).
+func NewMT(params MTParams, seed uint64) *MT {
+	return newMT(&params, seed)
+}
+
 func newMT(params *MTParams, seed uint64) *MT {
 	var lm uint64 = (1 << params.R) - 1
 
@@ -26,13 +31,18 @@ func newMT(params *MTParams, seed uint64) *MT {
 		umask:  ^lm & params.WMask,
 		params: params,
 	}
+	m.reseed(seed)
+	return m
+}
 
+// reseed reinitializes m's state using seed.
+func (m *MT) reseed(seed uint64) {
+	p := m.params
 	m.mt[0] = seed
-	for i := 1; i < params.N; i++ {
-		m.mt[i] = params.WMask & (params.F*(m.mt[i-1]^(m.mt[i-1]>>(params.W-2))) + uint64(i))
+	for i := 1; i < p.N; i++ {
+		m.mt[i] = p.WMask & (p.F*(m.mt[i-1]^(m.mt[i-1]>>(p.W-2))) + uint64(i))
 	}
-
-	return m
+	m.index = p.N
 }
 
 // Params returns a copy of the constant parameters used by the algorithm.
@@ -56,16 +66,93 @@ func (m *MT) Extract() uint64 {
 		m.twist()
 	}
 
-	y := m.mt[m.index]
+	y := m.Temper(m.mt[m.index])
+	m.index++
+	return y
+}
+
+// Temper applies the tempering transform used by Extract to y, a value from the
+// internal state array.
+func (m *MT) Temper(y uint64) uint64 {
 	y ^= (y >> m.params.U) & m.params.D
 	y ^= (y << m.params.S) & m.params.B
 	y ^= (y << m.params.T) & m.params.C
 	y ^= (y >> m.params.L)
-
-	m.index++
 	return y & m.params.WMask
 }
 
+// Untemper inverts Temper, returning the internal state value that produced
+// the output v.
+func (m *MT) Untemper(v uint64) uint64 {
+	p := m.params
+	v = undoShift(v, p.W, -p.L, p.WMask)
+	v = undoShift(v, p.W, p.T, p.C)
+	v = undoShift(v, p.W, p.S, p.B)
+	v = undoShift(v, p.W, -p.U, p.D)
+	return v
+}
+
+// undoShift solves for y after an operation of the form "v = y ^ ((y << s) & m)",
+// where y is a w-bit value. If s is negative, y was right-shifted by -s bits instead.
+//
+// The essence of each of the tempering steps in Extract is:
+// - shift Y left or right by S bits
+// - bitwise AND with mask M
+// - bitwise XOR against Y
+//
+// Another way of thinking about this is that we want to determine the value of
+// each bit in the original Y that would produce V. Since we're using shift and XOR operations,
+// a single bit can have an effect on multiple bits in the result.
+//
+// The challenge is that shift and AND operations are destructive.
+//
+// In the right-shift case:
+//   - The upper S bits are unchanged, since the shift brings in zeros which we then XOR against Y.
+//   - For the next S bits to the right, we know the bits from the shift (i.e. the upper S bits) and
+//     can apply the mask and then XOR against V's bits to get Y's bits.
+//   - Now that we know more bits from Y, we can repeat the previous operation until we know all of
+//     the bits from Y.
+//
+// The left-shift case is similar, except we need to solve starting from the rightmost bits.
+func undoShift(v uint64, w, s int, m uint64) uint64 {
+	sw := s
+	if sw < 0 {
+		sw = -sw
+	}
+	if sw == 0 || sw >= w {
+		panic(fmt.Sprintf("can't undo %v-bit shift of %v-bit number", sw, w))
+	}
+
+	var smask uint64 = (1 << sw) - 1 // mask for s bits
+	if s < 0 {
+		smask <<= w - sw // if the operation right-shifted, start from the left side
+	}
+
+	y := v & smask                            // preserve bits that were unchanged by the operation
+	for known := sw; known < w; known += sw { // solve for remaining groups of s bits
+		bmask := lshift(smask, known*sign(s)) // mask for the s bits being solved
+		prev := lshift(y, s)                  // already-known bits
+		y |= bmask & ((prev & m) ^ v)
+	}
+	return y
+}
+
+// lshift left-shifts v by s bits. If s is negative, it right-shifts instead.
+func lshift(v uint64, s int) uint64 {
+	if s < 0 {
+		return v >> -s
+	}
+	return v << s
+}
+
+// sign returns 1 if v is positive and -1 otherwise.
+func sign(v int) int {
+	if v > 0 {
+		return 1
+	}
+	return -1
+}
+
 func (m *MT) twist() {
 	for i := 0; i < m.params.N; i++ {
 		x := (m.mt[i] & m.umask) + (m.mt[(i+1)%m.params.N] & m.lmask)
@@ -78,9 +165,114 @@ func (m *MT) twist() {
 	m.index = 0
 }
 
+// Rewind moves m backward by n outputs, so that the next call to Extract returns the
+// value that was returned n calls earlier.
+func (m *MT) Rewind(n int) {
+	for ; n > 0; n-- {
+		if m.index == 0 {
+			m.untwist()
+			m.index = m.params.N
+		}
+		m.index--
+	}
+}
+
+// untwist inverts twist.
+//
+// Each new value produced by twist is the XOR of a value M positions later and xa, which is
+// derived from x, a combination of the upper W-R bits of the old value and the lower R bits
+// of the following old value. Since A's top bit is set and x is shifted right by one bit to
+// produce xa, xa's top bit reveals x's bottom bit, and the rest of x can then be recovered.
+// Working backward from the end of the array, each old value that's needed is already known.
+func (m *MT) untwist() {
+	p := m.params
+	if p.A>>(p.W-1) == 0 {
+		panic("can't untwist when A's top bit is unset")
+	}
+	unxa := func(xa uint64) uint64 {
+		if xa>>(p.W-1) != 0 {
+			return ((xa^p.A)<<1 | 1) & p.WMask
+		}
+		return (xa << 1) & p.WMask
+	}
+
+	old := make([]uint64, p.N)
+	for i := p.N - 1; i >= 0; i-- {
+		// twist updates the array in place, so values after the end have already wrapped.
+		var mid uint64
+		if j := i + p.M; j >= p.N {
+			mid = m.mt[j-p.N]
+		} else {
+			mid = old[j]
+		}
+		x := unxa(m.mt[i] ^ mid)
+		old[i] |= x & m.umask
+		if i+1 < p.N {
+			old[i+1] |= x & m.lmask
+		}
+	}
+	// The lower bits of the first old value weren't used to compute the new values, but
+	// assuming that the old values were also produced by twist, they can be recovered
+	// from the last old value.
+	old[0] |= unxa(old[p.N-1]^old[p.M-1]) & m.lmask
+	copy(m.mt, old)
+}
+
+// Seed returns the seed that was used to produce m, which must be at the start of its
+// sequence: either it hasn't produced any outputs yet, or it has been rewound (see Rewind)
+// to its first output. ErrNotSeeded is returned if m's state wasn't produced from a seed.
+//
+// The seed is found by inverting the recurrence used to initialize the state array:
+//
+//	mt[i] = F * (mt[i-1] ^ (mt[i-1] >> (W-2))) + i
+//
+// F is odd and thus has a multiplicative inverse modulo 2^W, and the XOR-and-shift can
+// be undone in the same way as the tempering steps. The first value (i.e. the seed itself)
+// can't be used directly since its lower bits are lost when the generator is untwisted.
+func (m *MT) Seed() (uint64, error) {
+	p := m.params
+	st := append([]uint64{}, m.mt...)
+	switch m.index {
+	case p.N: // initial state; not twisted yet
+	case 0: // twisted but no outputs extracted yet
+		c := *m
+		c.mt = st
+		c.untwist()
+	default:
+		return 0, fmt.Errorf("%w (generator is at index %v)", ErrNotSeeded, m.index)
+	}
+
+	// Compute F's inverse using Newton's method: each iteration doubles the number of
+	// correct low bits, and F is its own inverse modulo 8.
+	finv := p.F
+	for i := 0; i < 5; i++ {
+		finv *= 2 - p.F*finv
+	}
+	v := ((st[1] - 1) * finv) & p.WMask // mt[0] ^ (mt[0] >> (W-2))
+	seed := undoShift(v, p.W, -(p.W - 2), p.WMask)
+
+	// Check that the seed produces the rest of the state.
+	c := newMT(p, seed)
+	if c.mt[0]&m.umask != st[0]&m.umask {
+		return 0, ErrNotSeeded
+	}
+	for i := 1; i < p.N; i++ {
+		if c.mt[i] != st[i] {
+			return 0, ErrNotSeeded
+		}
+	}
+	return seed, nil
+}
+
 // NewMT19937 returns a new MT using the Mersenne prime 2^19937−1.
 func NewMT19937(seed uint64) *MT {
-	return newMT(&mt19937Params, seed)
+	return NewMT(MT19937Params, seed)
+}
+
+// NewMT19937_64 returns a new MT using the 64-bit version of MT19937
+// (i.e. std::mt19937_64 in C++).
+func NewMT19937_64(seed uint64) *MT {
+	return NewMT(MT19937_64Params, seed)
 }
 
 // Parameter values are listed at https://en.wikipedia.org/wiki/Mersenne_Twister.
@@ -98,7 +290,8 @@ type MTParams struct {
 	WMask uint64 // mask for bottom w bits
 }
 
-var mt19937Params = MTParams{
+// MT19937Params contains the parameters for the standard 32-bit MT19937.
+var MT19937Params = MTParams{
 	W:     32,
 	N:     624,
 	M:     397,
@@ -114,3 +307,21 @@ var mt19937Params = MTParams{
 	F:     1812433253,
 	WMask: (1 << 32) - 1,
 }
+
+// MT19937_64Params contains the parameters for the 64-bit MT19937-64.
+var MT19937_64Params = MTParams{
+	W:     64,
+	N:     312,
+	M:     156,
+	A:     0xB5026F5AA96619E9,
+	B:     0x71D67FFFEDA60000,
+	C:     0xFFF7EEE000000000,
+	D:     0x5555555555555555,
+	R:     31,
+	S:     17,
+	T:     37,
+	U:     29,
+	L:     43,
+	F:     6364136223846793005,
+	WMask: 1<<64 - 1,
+}
@@ -5,6 +5,8 @@
 package common
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -55,3 +57,193 @@ func TestMT19937(t *testing.T) {
 		}
 	}
 }
+
+func TestMT10000th(t *testing.T) {
+	// The C++ standard requires the 10000th invocation of default-constructed
+	// std::mt19937 and std::mt19937_64 objects (which use 5489 as their seed)
+	// to produce these values.
+	for _, tc := range []struct {
+		name string
+		mt   *MT
+		exp  uint64
+	}{
+		{"MT19937", NewMT19937(5489), 4123659995},
+		{"MT19937-64", NewMT19937_64(5489), 9981545732273789042},
+	} {
+		var n uint64
+		for i := 0; i < 10000; i++ {
+			n = tc.mt.Extract()
+		}
+		if n != tc.exp {
+			t.Errorf("%v produced %v for 10000th output; want %v", tc.name, n, tc.exp)
+		}
+	}
+}
+
+func TestUndoShift(t *testing.T) {
+	const (
+		mask = 0x1234FACE
+		orig = 0xDEADBEEF
+	)
+	for _, sw := range []int{1, 12, 16, 17, 31} {
+		val := uint64(orig) ^ (uint64(orig)>>sw)&mask
+		if rev := undoShift(val, 32, -sw, mask); rev != orig {
+			t.Errorf("Failed to undo %d-bit right shift:\ngot  %032b\nwant %032b", sw, rev, uint64(orig))
+		}
+		val = uint64(orig) ^ (uint64(orig)<<sw)&mask
+		if rev := undoShift(val, 32, sw, mask); rev != orig {
+			t.Errorf("Failed to undo %d-bit left shift:\ngot  %032b\nwant %032b", sw, rev, uint64(orig))
+		}
+	}
+}
+
+func TestMTUntemper(t *testing.T) {
+	r := NewSeededRand(1)
+	for _, mt := range []*MT{NewMT19937(1), NewMT19937_64(1)} {
+		p := mt.Params()
+		for i := 0; i < 1000; i++ {
+			y := uint64(r.Int64(1<<62)) & p.WMask
+			if got := mt.Untemper(mt.Temper(y)); got != y {
+				t.Fatalf("Untemper(Temper(%#x)) = %#x for %v-bit MT", y, got, p.W)
+			}
+		}
+	}
+}
+
+func TestCloneMT(t *testing.T) {
+	for _, params := range []MTParams{MT19937Params, MT19937_64Params} {
+		// Start partway through the sequence to check that the outputs needn't be aligned
+		// with the generator's twists.
+		mt := NewMT(params, 1234)
+		for i := 0; i < 100; i++ {
+			mt.Extract()
+		}
+		outs := make([]uint64, params.N+10)
+		for i := range outs {
+			outs[i] = mt.Extract()
+		}
+		clone, err := CloneMT(params, outs)
+		if err != nil {
+			t.Fatalf("CloneMT failed for %v-bit MT: %v", params.W, err)
+		}
+		for i := 0; i < 1000; i++ {
+			if a, b := mt.Extract(), clone.Extract(); a != b {
+				t.Fatalf("Clone of %v-bit MT produced %v; want %v", params.W, b, a)
+			}
+		}
+
+		if _, err := CloneMT(params, outs[:params.N-1]); !errors.Is(err, ErrNotEnoughOutputs) {
+			t.Errorf("CloneMT with too few outputs returned %v; want %v", err, ErrNotEnoughOutputs)
+		}
+		outs[len(outs)-1]++
+		if _, err := CloneMT(params, outs); !errors.Is(err, ErrBadOutputs) {
+			t.Errorf("CloneMT with bad outputs returned %v; want %v", err, ErrBadOutputs)
+		}
+	}
+}
+
+func TestRecoverMT(t *testing.T) {
+	for _, tc := range []struct {
+		params MTParams
+		num    int    // number of outputs to generate
+		skip   int    // skip every skip-th output (if nonzero)
+		mask   uint64 // observed bits
+	}{
+		{MT19937Params, 2600, 0, 0xff000000},
+		{MT19937Params, 1600, 5, 0xffffffff},
+		{MT19937_64Params, 1000, 5, 0xffffffffffffff00},
+	} {
+		mt := NewMT(tc.params, 5678)
+		var outs []MTOutput
+		for i := 0; i < tc.num; i++ {
+			v := mt.Extract()
+			if tc.skip == 0 || i%tc.skip != 0 {
+				outs = append(outs, MTOutput{Index: i, Value: v & tc.mask, Mask: tc.mask})
+			}
+		}
+		desc := fmt.Sprintf("%v-bit MT with %v outputs masked by %#x", tc.params.W, len(outs), tc.mask)
+		rec, err := RecoverMT(tc.params, outs)
+		if err != nil {
+			t.Errorf("RecoverMT failed for %v: %v", desc, err)
+			continue
+		}
+		orig := NewMT(tc.params, 5678)
+		for i := 0; i < tc.num+1000; i++ {
+			if a, b := orig.Extract(), rec.Extract(); a != b {
+				t.Errorf("Recovered %v produced %v at %v; want %v", desc, b, i, a)
+				break
+			}
+		}
+
+		if _, err := RecoverMT(tc.params, outs[:len(outs)/2]); !errors.Is(err, ErrNotEnoughOutputs) {
+			t.Errorf("RecoverMT with too few outputs for %v returned %v; want %v", desc, err, ErrNotEnoughOutputs)
+		}
+	}
+}
+
+func TestMTRewind(t *testing.T) {
+	for _, params := range []MTParams{MT19937Params, MT19937_64Params} {
+		const num = 2000
+		mt := NewMT(params, 1234)
+		outs := make([]uint64, num)
+		for i := range outs {
+			outs[i] = mt.Extract()
+		}
+		// Rewind across twists, then to the start of a twisted state array (whose first value
+		// is only partially recovered by untwisting), and then back to the first output.
+		pos := num
+		for _, n := range []int{5, params.N, 1, params.N + 10, 100, 3, -1, -2} {
+			switch n {
+			case -1:
+				n = pos % params.N
+			case -2:
+				n = pos
+			}
+			mt.Rewind(n)
+			pos -= n
+			for i := 0; i < 3; i++ {
+				if got, want := mt.Extract(), outs[pos+i]; got != want {
+					t.Fatalf("%v-bit MT produced %v for output %v after rewinding; want %v",
+						params.W, got, pos+i, want)
+				}
+			}
+			mt.Rewind(3)
+		}
+	}
+}
+
+func TestMTSeed(t *testing.T) {
+	for _, params := range []MTParams{MT19937Params, MT19937_64Params} {
+		const seed = 0x12345678
+		mt := NewMT(params, seed)
+		if got, err := mt.Seed(); err != nil || got != seed {
+			t.Errorf("Seed() for new %v-bit MT = %v, %v; want %v", params.W, got, err, uint64(seed))
+		}
+
+		// Clone the generator from its first outputs and then rewind to the start.
+		outs := make([]uint64, params.N)
+		for i := range outs {
+			outs[i] = mt.Extract()
+		}
+		clone, err := CloneMT(params, outs)
+		if err != nil {
+			t.Fatal("CloneMT failed: ", err)
+		}
+		if _, err := clone.Seed(); !errors.Is(err, ErrNotSeeded) {
+			t.Errorf("Seed() for %v-bit MT at index %v returned %v; want %v", params.W, params.N, err, ErrNotSeeded)
+		}
+		clone.Rewind(len(outs))
+		if got, err := clone.Seed(); err != nil || got != seed {
+			t.Errorf("Seed() for rewound %v-bit MT = %v, %v; want %v", params.W, got, err, uint64(seed))
+		}
+
+		st := make([]uint64, params.N)
+		for i := range st {
+			st[i] = uint64(i)
+		}
+		clone.SetState(st)
+		if _, err := clone.Seed(); !errors.Is(err, ErrNotSeeded) {
+			t.Errorf("Seed() for arbitrary %v-bit state returned %v; want %v", params.W, err, ErrNotSeeded)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"sync"
+)
+
+// Rand generates random values using bytes read from an io.Reader.
+// It is safe for concurrent use.
+type Rand struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+// NewRand returns a Rand that reads from r.
+func NewRand(r io.Reader) *Rand {
+	return &Rand{r: r}
+}
+
+// NewSecureRand returns a Rand that reads from crypto/rand.
+func NewSecureRand() *Rand {
+	return NewRand(rand.Reader)
+}
+
+// NewSeededRand returns a Rand that produces a deterministic (and insecure)
+// sequence derived from seed.
+func NewSeededRand(seed int64) *Rand {
+	return NewRand(mrand.New(mrand.NewSource(seed)))
+}
+
+// Read fills b with random bytes. It implements io.Reader.
+func (r *Rand) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return io.ReadFull(r.r, b)
+}
+
+// Bytes returns a slice of n random bytes.
+func (r *Rand) Bytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := r.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Int64 returns a random integer in the range [0, max).
+func (r *Rand) Int64(max int64) int64 {
+	v, err := rand.Int(r, big.NewInt(max))
+	if err != nil {
+		panic(err)
+	}
+	return v.Int64()
+}
+
+// Int returns a random integer in the range [0, max).
+func (r *Rand) Int(max int) int {
+	return int(r.Int64(int64(max)))
+}
+
+var (
+	defRand   = NewSecureRand()
+	defRandMu sync.RWMutex
+)
+
+// DefaultRand returns the Rand used by RandBytes, RandInt64, RandInt, and RandWord.
+func DefaultRand() *Rand {
+	defRandMu.RLock()
+	defer defRandMu.RUnlock()
+	return defRand
+}
+
+// SetRand makes RandBytes, RandInt64, RandInt, and RandWord use r.
+// The previous Rand is returned so it can be restored later.
+func SetRand(r *Rand) (prev *Rand) {
+	defRandMu.Lock()
+	defer defRandMu.Unlock()
+	prev, defRand = defRand, r
+	return prev
+}
+
+// SeedRand makes RandBytes, RandInt64, RandInt, and RandWord produce a deterministic
+// (and insecure) sequence derived from seed, so that runs can be reproduced.
+// A seed of 0 restores the default cryptographically-secure source.
+func SeedRand(seed int64) {
+	if seed == 0 {
+		SetRand(NewSecureRand())
+	} else {
+		SetRand(NewSeededRand(seed))
+	}
+}
+
+// RandBytes returns a slice of n random bytes from DefaultRand.
+// The bytes are cryptographically secure unless SetRand or SeedRand was called.
+func RandBytes(n int) []byte {
+	return DefaultRand().Bytes(n)
+}
+
+// RandInt64 returns a random integer in the range [0, max) from DefaultRand.
+func RandInt64(max int64) int64 {
+	return DefaultRand().Int64(max)
+}
+
+// RandInt returns a random integer in the range [0, max) from DefaultRand.
+func RandInt(max int) int {
+	return DefaultRand().Int(max)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRand(t *testing.T) {
+	gen := func(r *Rand) []byte { return append(r.Bytes(8), byte(r.Int(256)), byte(r.Int64(256))) }
+
+	a := gen(NewSeededRand(123))
+	if b := gen(NewSeededRand(123)); !bytes.Equal(a, b) {
+		t.Errorf("Got %x with same seed; want %x", b, a)
+	}
+	if c := gen(NewSeededRand(456)); bytes.Equal(a, c) {
+		t.Errorf("Got %x for different seeds", c)
+	}
+
+	// A Rand backed by a fixed buffer should return its bytes verbatim.
+	src := []byte{1, 2, 3, 4}
+	if got := NewRand(bytes.NewReader(src)).Bytes(len(src)); !bytes.Equal(got, src) {
+		t.Errorf("Bytes(%d) = %x; want %x", len(src), got, src)
+	}
+
+	r := NewSecureRand()
+	for i := 0; i < 100; i++ {
+		if v := r.Int(10); v < 0 || v >= 10 {
+			t.Fatalf("Int(10) returned %d", v)
+		}
+	}
+}
+
+func TestSeedRand(t *testing.T) {
+	defer SeedRand(0)
+
+	gen := func() []byte { return append(RandBytes(8), byte(RandInt(256))) }
+	SeedRand(123)
+	a := gen()
+	SeedRand(123)
+	if b := gen(); !bytes.Equal(a, b) {
+		t.Errorf("Got %x after reseeding with same seed; want %x", b, a)
+	}
+	SeedRand(456)
+	if c := gen(); bytes.Equal(a, c) {
+		t.Errorf("Got %x for different seeds", c)
+	}
+
+	// SetRand should return the previous Rand so it can be restored.
+	r := NewSeededRand(123)
+	prev := SetRand(r)
+	if DefaultRand() != r {
+		t.Error("DefaultRand didn't return Rand passed to SetRand")
+	}
+	if got := SetRand(prev); got != r {
+		t.Error("SetRand didn't return previous Rand")
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+// Scorer assigns scores to candidate plaintexts.
+// Higher scores indicate that a plaintext is more likely to be correct.
+// Scores are only comparable between plaintexts of the same length.
+type Scorer interface {
+	Score(b []byte) float64
+}
+
+// DefaultScorer scores plaintexts using EnglishScore. It orders plaintexts
+// in the same way as Score.Better.
+var DefaultScorer Scorer = englishScorer{}
+
+type englishScorer struct{}
+
+func (englishScorer) Score(b []byte) float64 {
+	s := EnglishScore(b)
+	// FreqDiff is in [0.0, 2.0], so scaling it keeps it from overriding differences in Chars.
+	return float64(s.Chars) - s.FreqDiff/4
+}
+
+// TextFreqs expands letters, a table of relative frequencies of 'A' through 'Z' (e.g.
+// EnglishUpperFreqs), into approximate frequencies of all bytes in running text, including
+// lowercase letters, spaces, punctuation, and digits. Other bytes receive a small non-zero
+// frequency. The returned frequencies sum to 1.
+func TextFreqs(letters [256]float64) [256]float64 {
+	return textFreqs(letters, 0.05)
+}
+
+// textFreqs implements TextFreqs. upperFrac is the fraction of letters that are uppercase.
+func textFreqs(letters [256]float64, upperFrac float64) [256]float64 {
+	const (
+		letterFrac = 0.78 // letters
+		spaceFrac  = 0.17 // spaces
+		otherFrac  = 0.05 // other printable characters and newlines
+		minFreq    = 1e-6 // everything else
+	)
+	var lsum float64
+	for _, f := range letters {
+		lsum += f
+	}
+	// Other printable characters: '!' through '~' (excluding letters) plus '\n'.
+	nother := float64('~'-' '-52) + 1
+
+	var freqs [256]float64
+	var total float64
+	for i := range freqs {
+		b := byte(i)
+		var f float64
+		switch {
+		case b >= 'A' && b <= 'Z':
+			f = letterFrac * upperFrac * letters[b] / lsum
+		case b >= 'a' && b <= 'z':
+			f = letterFrac * (1 - upperFrac) * letters[b-'a'+'A'] / lsum
+		case b == ' ':
+			f = spaceFrac
+		case b == '\n' || (b > ' ' && b <= '~'):
+			f = otherFrac / nother
+		}
+		freqs[i] = math.Max(f, minFreq)
+		total += freqs[i]
+	}
+	for i := range freqs {
+		freqs[i] /= total
+	}
+	return freqs
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// ChiSquaredScorer scores plaintexts using the negated chi-squared statistic of their
+// byte counts relative to the expected byte frequencies.
+type ChiSquaredScorer struct {
+	// Freqs contains the expected relative frequencies of all bytes, e.g. as returned by TextFreqs.
+	Freqs [256]float64
+}
+
+func (s *ChiSquaredScorer) Score(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	counts := CountBytes(b)
+	var chi float64
+	for i, c := range counts {
+		exp := math.Max(s.Freqs[i], 1e-9) * float64(len(b))
+		d := float64(c) - exp
+		chi += d * d / exp
+	}
+	return -chi
+}
+
+// NGramModel scores plaintexts by their log-likelihood under a model of n-byte sequences.
+// Unigram (n=1), bigram (n=2), and trigram (n=3) models are typically used.
+// Unseen n-grams are assigned a small probability using additive smoothing.
+type NGramModel struct {
+	n      int
+	counts map[string]float64
+	total  float64
+	unseen float64 // log-probability of an unseen n-gram
+}
+
+// smoothing is the pseudo-count added to each possible n-gram by NGramModel.
+const smoothing = 0.5
+
+func newNGramModel(n int, counts map[string]float64) *NGramModel {
+	m := &NGramModel{n: n, counts: counts}
+	for _, c := range counts {
+		m.total += c
+	}
+	m.unseen = math.Log(smoothing / (m.total + smoothing*math.Pow(256, float64(n))))
+	return m
+}
+
+// NewUnigramModel returns a unigram model using freqs, the expected relative frequencies
+// of all bytes (e.g. as returned by TextFreqs).
+func NewUnigramModel(freqs [256]float64) *NGramModel {
+	// Scale the frequencies to pseudo-counts so that smoothing has little effect.
+	counts := make(map[string]float64)
+	for i, f := range freqs {
+		if f > 0 {
+			counts[string([]byte{byte(i)})] = f * 1e6
+		}
+	}
+	return newNGramModel(1, counts)
+}
+
+// TrainNGram returns an n-gram model trained on all data read from r.
+func TrainNGram(r io.Reader, n int) (*NGramModel, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid n-gram length %v", n)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]float64)
+	for i := 0; i+n <= len(b); i++ {
+		counts[string(b[i:i+n])]++
+	}
+	return newNGramModel(n, counts), nil
+}
+
+// LoadNGram returns an n-gram model trained on the corpus file at p.
+func LoadNGram(p string, n int) (*NGramModel, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return TrainNGram(f, n)
+}
+
+// N returns the length of the n-grams used by m.
+func (m *NGramModel) N() int { return m.n }
+
+// LogProb returns the log-probability of gram, which should have length m.N().
+func (m *NGramModel) LogProb(gram []byte) float64 {
+	if c, ok := m.counts[string(gram)]; ok {
+		return math.Log((c + smoothing) / (m.total + smoothing*math.Pow(256, float64(m.n))))
+	}
+	return m.unseen
+}
+
+// Score returns the sum of the log-probabilities of all of the n-grams in b.
+func (m *NGramModel) Score(b []byte) float64 {
+	var sum float64
+	for i := 0; i+m.n <= len(b); i++ {
+		sum += m.LogProb(b[i : i+m.n])
+	}
+	return sum
+}
+
+// WordScorer scores plaintexts by the total length of the words in them that appear
+// in a word list. Words are delimited by non-letters and compared case-insensitively.
+type WordScorer struct {
+	words map[string]struct{}
+}
+
+// NewWordScorer returns a WordScorer that recognizes the supplied words.
+func NewWordScorer(words []string) *WordScorer {
+	s := &WordScorer{words: make(map[string]struct{}, len(words))}
+	for _, w := range words {
+		s.words[strings.ToLower(w)] = struct{}{}
+	}
+	return s
+}
+
+// LoadWordScorer returns a WordScorer that recognizes the words listed in the file at p,
+// one per line. See LoadWordList. DefaultWordList().Scorer() can be used to score
+// plaintexts using the default word list.
+func LoadWordScorer(p string) (*WordScorer, error) {
+	wl, err := LoadWordList(p)
+	if err != nil {
+		return nil, err
+	}
+	return wl.Scorer(), nil
+}
+
+func (s *WordScorer) Score(b []byte) float64 {
+	var total int
+	for _, w := range strings.FieldsFunc(strings.ToLower(string(b)), func(r rune) bool {
+		return r > 0x7f || !isASCIILetter(byte(r))
+	}) {
+		if _, ok := s.words[w]; ok {
+			total += len(w)
+		}
+	}
+	return float64(total)
+}
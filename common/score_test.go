@@ -0,0 +1,130 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCorpus is English text (distinct from testEnglish) used to train models in tests.
+const testCorpus = `It is a truth universally acknowledged, that a single man in possession of a good ` +
+	`fortune, must be in want of a wife. However little known the feelings or views of such a man ` +
+	`may be on his first entering a neighbourhood, this truth is so well fixed in the minds of the ` +
+	`surrounding families, that he is considered the rightful property of some one or other of ` +
+	`their daughters. "My dear Mr. Bennet," said his lady to him one day, "have you heard that ` +
+	`Netherfield Park is let at last?" Mr. Bennet replied that he had not. "But it is," returned ` +
+	`she; "for Mrs. Long has just been here, and she told me all about it." Mr. Bennet made no ` +
+	`answer. "Do you not want to know who has taken it?" cried his wife impatiently. "You want ` +
+	`to tell me, and I have no objection to hearing it." This was invitation enough. "Why, my ` +
+	`dear, you must know, Mrs. Long says that Netherfield is taken by a young man of large ` +
+	`fortune from the north of England; that he came down on Monday in a chaise and four to see ` +
+	`the place, and was so much delighted with it, that he agreed with Mr. Morris immediately; ` +
+	`that he is to take possession before Michaelmas, and some of his servants are to be in the ` +
+	`house by the end of next week."`
+
+// testFrench is French text used to test scoring of other languages.
+const testFrench = `Longtemps, je me suis couche de bonne heure. Parfois, a peine ma bougie eteinte, ` +
+	`mes yeux se fermaient si vite que je n'avais pas le temps de me dire: je m'endors. Et, une ` +
+	`demi-heure apres, la pensee qu'il etait temps de chercher le sommeil m'eveillait; je voulais ` +
+	`poser le volume que je croyais avoir encore dans les mains et souffler ma lumiere.`
+
+func trainTestModel(t *testing.T, n int) *NGramModel {
+	m, err := TrainNGram(strings.NewReader(testCorpus), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestScorers_SingleByteXOR(t *testing.T) {
+	const key = 0x5a
+	english := []byte(testEnglish[:200])
+	french := []byte(testFrench)
+	words := NewWordScorer(strings.Fields("it was the of times age we had all going"))
+
+	for _, tc := range []struct {
+		desc  string
+		s     Scorer
+		plain []byte
+	}{
+		{"default", DefaultScorer, english},
+		{"chi-squared", &ChiSquaredScorer{TextFreqs(EnglishUpperFreqs)}, english},
+		{"unigram", NewUnigramModel(TextFreqs(EnglishUpperFreqs)), english},
+		{"trained unigram", trainTestModel(t, 1), english},
+		{"bigram", trainTestModel(t, 2), english},
+		{"trigram", trainTestModel(t, 3), english},
+		{"words", words, english},
+		{"French chi-squared", &ChiSquaredScorer{TextFreqs(FrenchUpperFreqs)}, french},
+		{"French unigram", NewUnigramModel(TextFreqs(FrenchUpperFreqs)), french},
+	} {
+		enc := XOR(tc.plain, []byte{key})
+		if got := SingleByteXORScorer(enc, tc.s); got != key {
+			t.Errorf("SingleByteXORScorer with %v scorer = %#x; want %#x", tc.desc, got, key)
+		}
+	}
+}
+
+func TestScorers_Language(t *testing.T) {
+	// Each language's model should prefer text in that language.
+	english := []byte(testEnglish[:len(testFrench)])
+	french := []byte(testFrench)
+	en := &ChiSquaredScorer{TextFreqs(EnglishUpperFreqs)}
+	fr := &ChiSquaredScorer{TextFreqs(FrenchUpperFreqs)}
+	if en.Score(english) <= fr.Score(english) {
+		t.Error("French model scored English text higher than English model")
+	}
+	if fr.Score(french) <= en.Score(french) {
+		t.Error("English model scored French text higher than French model")
+	}
+}
+
+func TestBreakRepeatingXOR_Scorer(t *testing.T) {
+	// A trigram model should also rank the correct key first.
+	const key = "Terminator"
+	enc := XOR([]byte(testEnglish), []byte(key))
+	cands, err := BreakRepeatingXOR(enc, &XOROptions{Blocks: 8, Scorer: trainTestModel(t, 3)})
+	if err != nil {
+		t.Fatal("BreakRepeatingXOR failed: ", err)
+	}
+	if c := cands[0]; string(c.Key) != key || string(c.Plain) != testEnglish {
+		t.Errorf("BreakRepeatingXOR returned key %q and plaintext %q", c.Key, c.Plain)
+	}
+}
+
+func TestLoadScorers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "common_test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	corpus := filepath.Join(dir, "corpus.txt")
+	if err := ioutil.WriteFile(corpus, []byte("abcabc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadNGram(corpus, 2)
+	if err != nil {
+		t.Fatal("LoadNGram failed: ", err)
+	}
+	if m.Score([]byte("abca")) <= m.Score([]byte("acba")) {
+		t.Error("Bigram model didn't prefer seen bigrams")
+	}
+
+	list := filepath.Join(dir, "words.txt")
+	if err := ioutil.WriteFile(list, []byte("apple\nBanana\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := LoadWordScorer(list)
+	if err != nil {
+		t.Fatal("LoadWordScorer failed: ", err)
+	}
+	if got := ws.Score([]byte("An apple, a banana, and a cherry.")); got != 11 {
+		t.Errorf("WordScorer.Score = %v; want 11", got)
+	}
+}
@@ -7,94 +7,95 @@ package common
 import (
 	"bufio"
 	"encoding/base64"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"math"
-	"math/rand"
 	"os"
+	"strings"
 )
 
 // ReadBase64 reads base64 data from the file at p.
 // It panics on error.
 func ReadBase64(p string) []byte {
-	b, err := ioutil.ReadFile(p)
+	b, err := TryReadBase64(p)
 	if err != nil {
 		panic(err)
 	}
-	dec, err := base64.StdEncoding.DecodeString(string(b))
+	return b
+}
+
+// TryReadBase64 is like ReadBase64 but returns an error on failure.
+func TryReadBase64(p string) ([]byte, error) {
+	b, err := ioutil.ReadFile(p)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return dec
+	return base64.StdEncoding.DecodeString(string(b))
 }
 
 // ReadHexLines reads and decodes hex lines from p.
+// It panics on error.
 func ReadHexLines(p string) [][]byte {
-	f, err := os.Open(p)
+	bufs, err := TryReadHexLines(p)
 	if err != nil {
 		panic(err)
 	}
-	defer f.Close()
-
-	var bufs [][]byte
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		bufs = append(bufs, Unhex(sc.Text()))
-	}
-	if sc.Err() != nil {
-		panic(err)
-	}
 	return bufs
 }
 
-// ReadHexLines reads and decodes base64 lines from p.
+// TryReadHexLines is like ReadHexLines but returns an error on failure.
+func TryReadHexLines(p string) ([][]byte, error) {
+	return readLines(p, TryUnhex)
+}
+
+// ReadBase64Lines reads and decodes base64 lines from p.
+// It panics on error.
 func ReadBase64Lines(p string) [][]byte {
-	f, err := os.Open(p)
+	bufs, err := TryReadBase64Lines(p)
 	if err != nil {
 		panic(err)
 	}
-	defer f.Close()
-
-	var bufs [][]byte
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		dec, err := base64.StdEncoding.DecodeString(sc.Text())
-		if err != nil {
-			panic(err)
-		}
-		bufs = append(bufs, dec)
-	}
-	if sc.Err() != nil {
-		panic(err)
-	}
 	return bufs
 }
 
-// RandWord returns a randomly-chosen word from /usr/share/dict/words.
-// The maximum length of all words is also returned.
-func RandWord() (word string, maxLen int) {
-	rand.Seed(RandInt64(math.MaxInt64)) // rand package seeds with 1 by default
+// TryReadBase64Lines is like ReadBase64Lines but returns an error on failure.
+func TryReadBase64Lines(p string) ([][]byte, error) {
+	return readLines(p, base64.StdEncoding.DecodeString)
+}
 
-	f, err := os.Open("/usr/share/dict/words")
+// DecodeHexLines decodes each line in s as hex.
+func DecodeHexLines(s string) ([][]byte, error) {
+	return decodeLines(strings.NewReader(s), TryUnhex)
+}
+
+// DecodeBase64Lines decodes each line in s as base64.
+func DecodeBase64Lines(s string) ([][]byte, error) {
+	return decodeLines(strings.NewReader(s), base64.StdEncoding.DecodeString)
+}
+
+// readLines reads p and passes each line to dec.
+func readLines(p string, dec func(string) ([]byte, error)) ([][]byte, error) {
+	f, err := os.Open(p)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer f.Close()
+	return decodeLines(f, dec)
+}
 
-	nw := 0
-	sc := bufio.NewScanner(f)
-	for sc.Scan() {
-		nw++
-		w := sc.Text()
-		if len(w) > maxLen {
-			maxLen = len(w)
-		}
-		if rand.Float64() < 1/float64(nw) {
-			word = w
+// decodeLines reads r and passes each line to dec.
+func decodeLines(r io.Reader, dec func(string) ([]byte, error)) ([][]byte, error) {
+	var bufs [][]byte
+	sc := bufio.NewScanner(r)
+	for ln := 1; sc.Scan(); ln++ {
+		b, err := dec(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", ln, err)
 		}
+		bufs = append(bufs, b)
 	}
 	if err := sc.Err(); err != nil {
-		panic(err)
+		return nil, err
 	}
-
-	return word, maxLen
+	return bufs, nil
 }
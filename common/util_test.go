@@ -0,0 +1,62 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "common_test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(fn, data string) string {
+		p := filepath.Join(dir, fn)
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	want := [][]byte{[]byte("abc"), []byte("\x00\xff")}
+	if got, err := TryReadHexLines(write("good.hex", "616263\n00ff\n")); err != nil {
+		t.Errorf("TryReadHexLines failed: %v", err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Errorf("TryReadHexLines returned %q; want %q", got, want)
+	}
+	if got, err := TryReadBase64Lines(write("good.b64", "YWJj\nAP8=\n")); err != nil {
+		t.Errorf("TryReadBase64Lines failed: %v", err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Errorf("TryReadBase64Lines returned %q; want %q", got, want)
+	}
+
+	if got, err := DecodeHexLines("616263\n00ff"); err != nil {
+		t.Errorf("DecodeHexLines failed: %v", err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeHexLines returned %q; want %q", got, want)
+	}
+	if got, err := DecodeBase64Lines("YWJj\nAP8=\n"); err != nil {
+		t.Errorf("DecodeBase64Lines failed: %v", err)
+	} else if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeBase64Lines returned %q; want %q", got, want)
+	}
+
+	if _, err := TryReadHexLines(write("bad.hex", "616263\nxyz\n")); err == nil {
+		t.Error("TryReadHexLines unexpectedly accepted invalid hex")
+	}
+	if _, err := TryReadBase64Lines(write("bad.b64", "YWJj\n!!!\n")); err == nil {
+		t.Error("TryReadBase64Lines unexpectedly accepted invalid base64")
+	}
+	if _, err := TryReadBase64(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("TryReadBase64 with missing file returned %v; want not-exist error", err)
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/derat/cryptopals/md4"
+	"github.com/derat/cryptopals/sha1"
+)
+
+// CompressFunc is a Merkle–Damgård compression function.
+// It returns the chaining value produced by processing block starting from state.
+type CompressFunc func(state uint64, block []byte) uint64
+
+// WeakHash is a Merkle–Damgård hash function with a deliberately small chaining value,
+// which makes generic attacks that require 2^(Bits/2) or more compression function calls
+// practical. Messages are padded like SHA-1's: a 1 bit, zero bits, and the message's
+// length in bits as a 64-bit big-endian integer.
+type WeakHash struct {
+	Compress  CompressFunc // must return values that fit in Bits bits
+	BlockSize int          // in bytes; must be at least 9
+	Bits      int          // width of chaining values, in [1, 64]
+	IV        uint64       // initial chaining value
+}
+
+// widthMask returns a mask covering the low bits bits of a uint64.
+func widthMask(bits int) uint64 {
+	if bits < 1 || bits > 64 {
+		panic(fmt.Sprintf("invalid width %v", bits))
+	}
+	return 1<<bits - 1 // 1<<64 is 0
+}
+
+// NewAESWeakHash returns a WeakHash with 16-byte blocks whose compression function encrypts
+// the chaining value (as a zero-padded big-endian integer) using AES-128 with the block as
+// the key and returns the result's low bits bits. This is the function from challenge 52.
+func NewAESWeakHash(bits int) *WeakHash {
+	m := widthMask(bits)
+	return &WeakHash{
+		Compress: func(state uint64, block []byte) uint64 {
+			var b [16]byte
+			binary.BigEndian.PutUint64(b[8:], state)
+			return binary.BigEndian.Uint64(EncryptAES(b[:], block, nil)[8:]) & m
+		},
+		BlockSize: 16,
+		Bits:      bits,
+		IV:        0x0123456789abcdef & m,
+	}
+}
+
+// NewSHA1WeakHash returns a WeakHash with 64-byte blocks whose compression function XORs the
+// chaining value into the low words of SHA-1's IV, runs SHA-1's compression function,
+// and returns the low bits bits of the result.
+func NewSHA1WeakHash(bits int) *WeakHash {
+	m := widthMask(bits)
+	iv, _ := sha1.State(sha1.New())
+	return &WeakHash{
+		Compress: func(state uint64, block []byte) uint64 {
+			s := iv
+			s[0] ^= uint32(state)
+			s[1] ^= uint32(state >> 32)
+			s = sha1.Compress(s, block)
+			return (uint64(s[1])<<32 | uint64(s[0])) & m
+		},
+		BlockSize: sha1.BlockSize,
+		Bits:      bits,
+	}
+}
+
+// NewMD4WeakHash is like NewSHA1WeakHash but uses MD4's compression function.
+func NewMD4WeakHash(bits int) *WeakHash {
+	m := widthMask(bits)
+	iv, _ := md4.State(md4.New())
+	return &WeakHash{
+		Compress: func(state uint64, block []byte) uint64 {
+			s := iv
+			s[0] ^= uint32(state)
+			s[1] ^= uint32(state >> 32)
+			s = md4.Compress(s, block)
+			return (uint64(s[1])<<32 | uint64(s[0])) & m
+		},
+		BlockSize: md4.BlockSize,
+		Bits:      bits,
+	}
+}
+
+// Pad returns the padding that is appended to a message of n bytes.
+func (h *WeakHash) Pad(n int) []byte {
+	p := []byte{0x80}
+	for (n+len(p)+8)%h.BlockSize != 0 {
+		p = append(p, 0)
+	}
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(n)*8)
+	return append(p, l[:]...)
+}
+
+// Chain returns the chaining value produced by processing msg starting from state.
+// msg's length must be a multiple of h.BlockSize. No padding is added.
+func (h *WeakHash) Chain(state uint64, msg []byte) uint64 {
+	if len(msg)%h.BlockSize != 0 {
+		panic(fmt.Sprintf("%v-byte message isn't multiple of block size %v", len(msg), h.BlockSize))
+	}
+	for i := 0; i < len(msg); i += h.BlockSize {
+		state = h.Compress(state, msg[i:i+h.BlockSize])
+	}
+	return state
+}
+
+// Sum returns the hash of msg.
+func (h *WeakHash) Sum(msg []byte) uint64 {
+	p := append(append([]byte{}, msg...), h.Pad(len(msg))...)
+	return h.Chain(h.IV, p)
+}
+
+// randBlock returns a random block obtained from DefaultRand.
+func (h *WeakHash) randBlock() []byte {
+	return RandBytes(h.BlockSize)
+}
+
+// FindCollision uses a birthday attack to find blocks b1 and b2 such that compressing b1
+// starting from s1 produces the same chaining value (state) as compressing b2 starting from s2.
+// If s1 and s2 are equal, b1 and b2 will differ. About 2^(h.Bits/2) blocks are tried.
+func (h *WeakHash) FindCollision(s1, s2 uint64) (b1, b2 []byte, state uint64) {
+	seen1 := make(map[uint64][]byte) // outputs from s1
+	seen2 := seen1                   // outputs from s2
+	if s1 != s2 {
+		seen2 = make(map[uint64][]byte)
+	}
+	for {
+		b := h.randBlock()
+		v := h.Compress(s1, b)
+		if o, ok := seen2[v]; ok && (s1 != s2 || string(o) != string(b)) {
+			return b, o, v
+		}
+		seen1[v] = b
+
+		if s1 != s2 {
+			b = h.randBlock()
+			v = h.Compress(s2, b)
+			if o, ok := seen1[v]; ok {
+				return o, b, v
+			}
+			seen2[v] = b
+		}
+	}
+}
+
+// Multicollision is a set of messages that all produce the same chaining value, built as
+// described in Joux's "Multicollisions in Iterated Hash Functions". Choosing either block
+// from each pair in Pairs yields one of 2^len(Pairs) colliding messages.
+type Multicollision struct {
+	Pairs [][2][]byte // colliding blocks
+	State uint64      // chaining value after processing any of the messages
+}
+
+// Multicollision finds a 2^k-way multicollision starting from state.
+// Only k collisions are needed, so about k*2^(h.Bits/2) blocks are tried.
+func (h *WeakHash) Multicollision(state uint64, k int) *Multicollision {
+	mc := &Multicollision{State: state}
+	for i := 0; i < k; i++ {
+		b1, b2, next := h.FindCollision(mc.State, mc.State)
+		mc.Pairs = append(mc.Pairs, [2][]byte{b1, b2})
+		mc.State = next
+	}
+	return mc
+}
+
+// Count returns the number of colliding messages, i.e. 2^len(mc.Pairs).
+// It panics if there are 64 or more pairs.
+func (mc *Multicollision) Count() uint64 {
+	if len(mc.Pairs) >= 64 {
+		panic("too many messages to count")
+	}
+	return 1 << len(mc.Pairs)
+}
+
+// Message returns the i-th colliding message.
+// Bit j of i selects the block used from mc.Pairs[j].
+func (mc *Multicollision) Message(i uint64) []byte {
+	var m []byte
+	for j, p := range mc.Pairs {
+		m = append(m, p[(i>>j)&1]...)
+	}
+	return m
+}
+
+// ExpandableMessage can produce messages of any length between k and k+2^k-1 blocks that
+// all produce the same chaining value, as described in Kelsey and Schneier's "Second
+// Preimages on n-bit Hash Functions for Much Less than 2^n Work".
+type ExpandableMessage struct {
+	State uint64 // chaining value after processing any of the messages
+
+	// short[i] is a single block and long[i] is 2^(k-1-i)+1 blocks.
+	// Both produce the same chaining value when appended to either piece from level i-1.
+	short, long [][]byte
+}
+
+// ExpandableMessage builds an ExpandableMessage starting from state.
+// About k*2^(h.Bits/2) + 2^k blocks are processed.
+func (h *WeakHash) ExpandableMessage(state uint64, k int) *ExpandableMessage {
+	em := &ExpandableMessage{State: state}
+	for i := 0; i < k; i++ {
+		// Collide a single block with a long run of arbitrary blocks followed by a final block.
+		dummy := make([]byte, (1<<(k-1-i))*h.BlockSize)
+		b1, b2, next := h.FindCollision(em.State, h.Chain(em.State, dummy))
+		em.short = append(em.short, b1)
+		em.long = append(em.long, append(dummy, b2...))
+		em.State = next
+	}
+	return em
+}
+
+// MinBlocks returns the length of the shortest message that em can produce.
+func (em *ExpandableMessage) MinBlocks() int { return len(em.short) }
+
+// MaxBlocks returns the length of the longest message that em can produce.
+func (em *ExpandableMessage) MaxBlocks() int { return len(em.short) + 1<<len(em.short) - 1 }
+
+// Message returns a message of the requested number of blocks.
+func (em *ExpandableMessage) Message(blocks int) ([]byte, error) {
+	if blocks < em.MinBlocks() || blocks > em.MaxBlocks() {
+		return nil, fmt.Errorf("can't produce %v-block message (need [%v, %v])",
+			blocks, em.MinBlocks(), em.MaxBlocks())
+	}
+	k := len(em.short)
+	extra := blocks - k // blocks beyond one per level
+	var m []byte
+	for i := 0; i < k; i++ {
+		if extra&(1<<(k-1-i)) != 0 {
+			m = append(m, em.long[i]...)
+		} else {
+			m = append(m, em.short[i]...)
+		}
+	}
+	return m, nil
+}
+
+// SecondPreimage returns a different message with the same length and hash as msg using
+// Kelsey and Schneier's attack. msg must contain at least 2 full blocks, and the attack gets
+// cheaper as msg gets longer: an expandable message is built to cover its length and then
+// about 2^h.Bits/n blocks are tried to link the expandable message to one of msg's n
+// intermediate chaining values.
+func (h *WeakHash) SecondPreimage(msg []byte) ([]byte, error) {
+	nb := len(msg) / h.BlockSize
+	if nb < 2 {
+		return nil, fmt.Errorf("message has %v full block(s); need at least 2", nb)
+	}
+	k := 1
+	for k+1<<k < nb {
+		k++
+	}
+
+	// Find the chaining values after each block that can be reached using a k-level
+	// expandable message followed by a single linking block.
+	targets := make(map[uint64]int) // chaining value after block i (1-indexed) to i
+	state := h.IV
+	for i := 1; i <= nb; i++ {
+		state = h.Compress(state, msg[(i-1)*h.BlockSize:i*h.BlockSize])
+		if i >= k+1 {
+			if _, ok := targets[state]; !ok {
+				targets[state] = i
+			}
+		}
+	}
+
+	em := h.ExpandableMessage(h.IV, k)
+	for {
+		b := h.randBlock()
+		i, ok := targets[h.Compress(em.State, b)]
+		if !ok {
+			continue
+		}
+		pre, err := em.Message(i - 1)
+		if err != nil {
+			return nil, err
+		}
+		m := append(append(pre, b...), msg[i*h.BlockSize:]...)
+		if string(m) == string(msg) {
+			continue // astronomically unlikely
+		}
+		return m, nil
+	}
+}
+
+// Diamond is a binary tree of collisions that funnels 2^k chaining values into a single
+// root value, as described in Kelsey and Kohno's "Herding Hash Functions and the Nostradamus
+// Attack". It can be used to commit to a hash before choosing a message's prefix.
+type Diamond struct {
+	h      *WeakHash
+	leaves map[uint64]int // leaf chaining value to index in levels[0]
+	levels [][]diamondNode
+	Root   uint64 // chaining value at the root of the tree
+}
+
+// diamondNode is a node in a Diamond.
+type diamondNode struct {
+	state uint64 // chaining value at this node
+	block []byte // block leading from state to the parent's state
+}
+
+// BuildDiamond builds a Diamond with 2^k leaves. k must be less than h.Bits.
+// 2^k-1 collisions are needed, so about 2^(k+h.Bits/2) blocks are tried.
+func (h *WeakHash) BuildDiamond(k int) *Diamond {
+	if k >= h.Bits {
+		panic(fmt.Sprintf("can't build %v-level diamond for %v-bit hash", k, h.Bits))
+	}
+	d := &Diamond{h: h, leaves: make(map[uint64]int)}
+
+	m := widthMask(h.Bits)
+	leaves := make([]diamondNode, 0, 1<<k)
+	for len(leaves) < 1<<k {
+		s := binary.BigEndian.Uint64(RandBytes(8)) & m
+		if _, ok := d.leaves[s]; !ok {
+			d.leaves[s] = len(leaves)
+			leaves = append(leaves, diamondNode{state: s})
+		}
+	}
+	d.levels = append(d.levels, leaves)
+
+	for cur := leaves; len(cur) > 1; cur = d.levels[len(d.levels)-1] {
+		next := make([]diamondNode, len(cur)/2)
+		for i := range next {
+			b1, b2, s := h.FindCollision(cur[2*i].state, cur[2*i+1].state)
+			cur[2*i].block, cur[2*i+1].block = b1, b2
+			next[i].state = s
+		}
+		d.levels = append(d.levels, next)
+	}
+	d.Root = d.levels[len(d.levels)-1][0].state
+	return d
+}
+
+// Predict returns the hash of messages returned by Herd for a prefix of prefixLen bytes.
+func (d *Diamond) Predict(prefixLen int) uint64 {
+	n := prefixLen + len(d.levels)*d.h.BlockSize // linking block and one block per level
+	return d.h.Chain(d.Root, d.h.Pad(n))
+}
+
+// Herd returns a message consisting of prefix followed by a linking block and the blocks
+// leading from one of d's leaves to its root, so that its hash is d.Predict(len(prefix)).
+// prefix's length must be a multiple of the block size. About 2^(h.Bits-k) blocks are tried
+// to find the linking block.
+func (d *Diamond) Herd(prefix []byte) ([]byte, error) {
+	h := d.h
+	if len(prefix)%h.BlockSize != 0 {
+		return nil, fmt.Errorf("%w (prefix is %v bytes)", ErrPartialBlock, len(prefix))
+	}
+	state := h.Chain(h.IV, prefix)
+	for {
+		b := h.randBlock()
+		idx, ok := d.leaves[h.Compress(state, b)]
+		if !ok {
+			continue
+		}
+		m := append(append([]byte{}, prefix...), b...)
+		for _, level := range d.levels[:len(d.levels)-1] {
+			m = append(m, level[idx].block...)
+			idx /= 2
+		}
+		return m, nil
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWeakHash(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		h    *WeakHash
+	}{
+		{"AES", NewAESWeakHash(16)},
+		{"SHA-1", NewSHA1WeakHash(20)},
+		{"MD4", NewMD4WeakHash(24)},
+	} {
+		h := tc.h
+		for _, n := range []int{0, 1, h.BlockSize - 9, h.BlockSize - 8, h.BlockSize, 3 * h.BlockSize} {
+			if p := h.Pad(n); (n+len(p))%h.BlockSize != 0 {
+				t.Errorf("%v Pad(%v) returned %v bytes", tc.name, n, len(p))
+			}
+			if s := h.Sum(A(n)); s>>h.Bits != 0 {
+				t.Errorf("%v Sum of %v bytes = %#x; want %v-bit value", tc.name, n, s, h.Bits)
+			}
+		}
+		if a, b := h.Sum([]byte("abc")), h.Sum([]byte("abd")); a == b {
+			t.Errorf("%v Sum unexpectedly produced %#x for different messages", tc.name, a)
+		}
+
+		mc := h.Multicollision(h.IV, 3)
+		seen := make(map[string]bool)
+		for i := uint64(0); i < mc.Count(); i++ {
+			m := mc.Message(i)
+			seen[string(m)] = true
+			if s := h.Chain(h.IV, m); s != mc.State {
+				t.Errorf("%v multicollision message %v produced %#x; want %#x", tc.name, i, s, mc.State)
+			}
+		}
+		if len(seen) != 8 {
+			t.Errorf("%v multicollision produced %v distinct message(s); want 8", tc.name, len(seen))
+		}
+	}
+}
+
+func TestExpandableMessage(t *testing.T) {
+	h := NewAESWeakHash(16)
+	em := h.ExpandableMessage(h.IV, 4)
+	if em.MinBlocks() != 4 || em.MaxBlocks() != 19 {
+		t.Errorf("Expandable message has range [%v, %v]; want [4, 19]", em.MinBlocks(), em.MaxBlocks())
+	}
+	for n := em.MinBlocks(); n <= em.MaxBlocks(); n++ {
+		m, err := em.Message(n)
+		if err != nil {
+			t.Errorf("Message(%v) failed: %v", n, err)
+			continue
+		}
+		if len(m) != n*h.BlockSize {
+			t.Errorf("Message(%v) returned %v bytes; want %v", n, len(m), n*h.BlockSize)
+		}
+		if s := h.Chain(h.IV, m); s != em.State {
+			t.Errorf("Message(%v) produced %#x; want %#x", n, s, em.State)
+		}
+	}
+	for _, n := range []int{em.MinBlocks() - 1, em.MaxBlocks() + 1} {
+		if _, err := em.Message(n); err == nil {
+			t.Errorf("Message(%v) unexpectedly succeeded", n)
+		}
+	}
+}
+
+func TestSecondPreimage(t *testing.T) {
+	h := NewAESWeakHash(20)
+	msg := RandBytes(200*h.BlockSize + 5)
+	m, err := h.SecondPreimage(msg)
+	if err != nil {
+		t.Fatal("SecondPreimage failed: ", err)
+	}
+	if bytes.Equal(m, msg) {
+		t.Error("SecondPreimage returned original message")
+	}
+	if len(m) != len(msg) {
+		t.Errorf("SecondPreimage returned %v bytes; want %v", len(m), len(msg))
+	}
+	if got, want := h.Sum(m), h.Sum(msg); got != want {
+		t.Errorf("SecondPreimage returned message with hash %#x; want %#x", got, want)
+	}
+	if _, err := h.SecondPreimage(A(h.BlockSize)); err == nil {
+		t.Error("SecondPreimage unexpectedly succeeded for 1-block message")
+	}
+}
+
+func TestDiamond(t *testing.T) {
+	h := NewMD4WeakHash(16)
+	d := h.BuildDiamond(6)
+	for _, prefix := range [][]byte{nil, A(2 * h.BlockSize)} {
+		m, err := d.Herd(prefix)
+		if err != nil {
+			t.Errorf("Herd with %v-byte prefix failed: %v", len(prefix), err)
+			continue
+		}
+		if !bytes.HasPrefix(m, prefix) {
+			t.Errorf("Herd returned %q without prefix %q", m, prefix)
+		}
+		if got, want := h.Sum(m), d.Predict(len(prefix)); got != want {
+			t.Errorf("Herd with %v-byte prefix produced hash %#x; want %#x", len(prefix), got, want)
+		}
+	}
+	if _, err := d.Herd(A(10)); !errors.Is(err, ErrPartialBlock) {
+		t.Errorf("Herd with 10-byte prefix returned %v; want %v", err, ErrPartialBlock)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// WordsEnv is the name of an environment variable that can be set to the path of a
+// file containing one word per line (e.g. /usr/share/dict/words). If it is set,
+// DefaultWordList loads the file instead of using the embedded word list.
+const WordsEnv = "CRYPTOPALS_WORDS"
+
+//go:embed words.txt
+var embeddedWords string
+
+// WordList is an immutable list of words.
+type WordList struct {
+	words  []string
+	maxLen int
+}
+
+// NewWordList returns a WordList containing words.
+// Empty strings are skipped.
+func NewWordList(words []string) *WordList {
+	wl := &WordList{words: make([]string, 0, len(words))}
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		wl.words = append(wl.words, w)
+		if len(w) > wl.maxLen {
+			wl.maxLen = len(w)
+		}
+	}
+	return wl
+}
+
+// ReadWordList reads a WordList from r, which should contain one word per line.
+// Leading and trailing whitespace is trimmed and empty lines are skipped.
+// ErrNoWords is returned if r doesn't contain any words.
+func ReadWordList(r io.Reader) (*WordList, error) {
+	var words []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if w := strings.TrimSpace(sc.Text()); w != "" {
+			words = append(words, w)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, ErrNoWords
+	}
+	return NewWordList(words), nil
+}
+
+// LoadWordList is like ReadWordList but reads the file at p.
+func LoadWordList(p string) (*WordList, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadWordList(f)
+}
+
+// Len returns the number of words in wl.
+func (wl *WordList) Len() int { return len(wl.words) }
+
+// Word returns the i-th word in wl.
+func (wl *WordList) Word(i int) string { return wl.words[i] }
+
+// MaxLen returns the length in bytes of the longest word in wl.
+func (wl *WordList) MaxLen() int { return wl.maxLen }
+
+// Each calls f with each word in wl in order, stopping early if f returns false.
+func (wl *WordList) Each(f func(w string) bool) {
+	for _, w := range wl.words {
+		if !f(w) {
+			return
+		}
+	}
+}
+
+// Rand returns a word chosen from wl using r.
+func (wl *WordList) Rand(r *Rand) string {
+	return wl.words[r.Int(len(wl.words))]
+}
+
+// Scorer returns a WordScorer that recognizes the words in wl.
+func (wl *WordList) Scorer() *WordScorer {
+	return NewWordScorer(wl.words)
+}
+
+var (
+	defWords    *WordList
+	defWordsErr error
+	defWordsMu  sync.Mutex
+)
+
+// DefaultWordList returns the word list used by RandWord.
+// It is loaded from the file named by WordsEnv if that environment variable is set,
+// and otherwise consists of a list of common English words embedded in the binary.
+// It panics on error.
+func DefaultWordList() *WordList {
+	wl, err := TryDefaultWordList()
+	if err != nil {
+		panic(err)
+	}
+	return wl
+}
+
+// TryDefaultWordList is like DefaultWordList but returns an error on failure.
+func TryDefaultWordList() (*WordList, error) {
+	defWordsMu.Lock()
+	defer defWordsMu.Unlock()
+	if defWords == nil && defWordsErr == nil {
+		if p := os.Getenv(WordsEnv); p != "" {
+			defWords, defWordsErr = LoadWordList(p)
+		} else {
+			defWords, defWordsErr = ReadWordList(strings.NewReader(embeddedWords))
+		}
+	}
+	return defWords, defWordsErr
+}
+
+// SetWordList makes DefaultWordList return wl. If wl is nil, the default list
+// will be loaded again on the next call to DefaultWordList.
+func SetWordList(wl *WordList) {
+	defWordsMu.Lock()
+	defer defWordsMu.Unlock()
+	defWords, defWordsErr = wl, nil
+}
+
+// RandWord returns a word chosen from DefaultWordList using DefaultRand.
+// The maximum length of all words in the list is also returned.
+// It panics on error.
+func RandWord() (word string, maxLen int) {
+	word, maxLen, err := TryRandWord()
+	if err != nil {
+		panic(err)
+	}
+	return word, maxLen
+}
+
+// TryRandWord is like RandWord but returns an error on failure.
+func TryRandWord() (word string, maxLen int, err error) {
+	wl, err := TryDefaultWordList()
+	if err != nil {
+		return "", 0, err
+	}
+	return wl.Rand(DefaultRand()), wl.MaxLen(), nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWordList(t *testing.T) {
+	wl, err := ReadWordList(strings.NewReader("apple\n  banana \n\ncherry\n"))
+	if err != nil {
+		t.Fatal("ReadWordList failed: ", err)
+	}
+	if got, want := wl.Len(), 3; got != want {
+		t.Errorf("Len() = %v; want %v", got, want)
+	}
+	if got, want := wl.MaxLen(), 6; got != want {
+		t.Errorf("MaxLen() = %v; want %v", got, want)
+	}
+	if got, want := wl.Word(1), "banana"; got != want {
+		t.Errorf("Word(1) = %q; want %q", got, want)
+	}
+
+	var got []string
+	wl.Each(func(w string) bool {
+		got = append(got, w)
+		return len(got) < 2
+	})
+	if want := []string{"apple", "banana"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Each visited %q; want %q", got, want)
+	}
+
+	if w := wl.Rand(NewSeededRand(1)); w != "apple" && w != "banana" && w != "cherry" {
+		t.Errorf("Rand returned %q", w)
+	}
+	if got := wl.Scorer().Score([]byte("An apple and a cherry")); got != 11 {
+		t.Errorf("Scorer().Score = %v; want 11", got)
+	}
+
+	if _, err := ReadWordList(strings.NewReader("\n \n")); !errors.Is(err, ErrNoWords) {
+		t.Errorf("ReadWordList with no words returned %v; want %v", err, ErrNoWords)
+	}
+}
+
+func TestDefaultWordList(t *testing.T) {
+	defer SetWordList(nil)
+	defer SeedRand(0)
+	defer os.Setenv(WordsEnv, os.Getenv(WordsEnv))
+
+	os.Unsetenv(WordsEnv)
+	SetWordList(nil)
+	wl := DefaultWordList()
+	if wl.Len() < 1000 {
+		t.Errorf("Embedded word list only has %v words", wl.Len())
+	}
+	if got := wl.Scorer().Score([]byte("a terrible beauty is born")); got != 21 {
+		t.Errorf("Embedded word list scored %v; want 21", got)
+	}
+
+	SeedRand(1)
+	w1, ml := RandWord()
+	if ml != wl.MaxLen() {
+		t.Errorf("RandWord returned max length %v; want %v", ml, wl.MaxLen())
+	}
+	SeedRand(1)
+	if w2, _ := RandWord(); w2 != w1 {
+		t.Errorf("RandWord returned %q after reseeding; want %q", w2, w1)
+	}
+
+	SetWordList(NewWordList([]string{"only"}))
+	if w, ml := RandWord(); w != "only" || ml != 4 {
+		t.Errorf("RandWord() = %q, %v; want %q, %v", w, ml, "only", 4)
+	}
+
+	os.Setenv(WordsEnv, "/nonexistent/words")
+	SetWordList(nil)
+	if _, _, err := TryRandWord(); err == nil {
+		t.Errorf("TryRandWord unexpectedly succeeded with %v set to missing file", WordsEnv)
+	}
+}
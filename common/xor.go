@@ -0,0 +1,185 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// XOROptions configures BreakRepeatingXOR.
+type XOROptions struct {
+	// MinKeySize and MaxKeySize bound the key sizes that are considered.
+	// If zero, 2 and 40 are used. MaxKeySize is reduced if the ciphertext is too short
+	// to contain Blocks blocks of that size.
+	MinKeySize, MaxKeySize int
+	// Blocks is the number of key-sized blocks from the start of the ciphertext whose
+	// pairwise Hamming distances are averaged to rank each key size. If zero, 4 is used.
+	Blocks int
+	// KeySizes is the number of top-ranked key sizes for which keys are recovered.
+	// If zero, 5 is used.
+	KeySizes int
+	// Scorer is used to score candidate plaintexts, both when recovering each byte of a key
+	// and when ranking keys. If nil, DefaultScorer is used.
+	Scorer Scorer
+}
+
+// XORCandidate is a possible solution found by BreakRepeatingXOR.
+type XORCandidate struct {
+	Key   []byte
+	Plain []byte
+	Score float64 // from XOROptions.Scorer; higher is better
+	// Dist is the average normalized Hamming distance between blocks of len(Key) bytes.
+	// Lower distances suggest that the key size is correct.
+	Dist float64
+}
+
+// BreakRepeatingXOR attempts to decrypt enc, which was encrypted using repeating-key XOR.
+// Candidates are returned ordered from most to least likely, with at most one candidate
+// for each of the top opts.KeySizes key sizes. Keys that consist of a shorter key repeated
+// multiple times (as happens for multiples of the actual key size) are shortened, even if
+// a few of their bytes differ, and duplicate keys are omitted. opts may be nil.
+func BreakRepeatingXOR(enc []byte, opts *XOROptions) ([]XORCandidate, error) {
+	var o XOROptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MinKeySize <= 0 {
+		o.MinKeySize = 2
+	}
+	if o.MaxKeySize <= 0 {
+		o.MaxKeySize = 40
+	}
+	if o.Blocks <= 0 {
+		o.Blocks = 4
+	}
+	if o.KeySizes <= 0 {
+		o.KeySizes = 5
+	}
+	if o.Scorer == nil {
+		o.Scorer = DefaultScorer
+	}
+	if o.Blocks < 2 {
+		return nil, fmt.Errorf("need at least 2 blocks to compare; got %v", o.Blocks)
+	}
+	if max := len(enc) / o.Blocks; o.MaxKeySize > max {
+		o.MaxKeySize = max
+	}
+	if o.MaxKeySize < o.MinKeySize {
+		return nil, fmt.Errorf("can't check %v blocks of size %v in input of size %v",
+			o.Blocks, o.MinKeySize, len(enc))
+	}
+
+	// Rank key sizes by the Hamming distances between blocks. Bytes encrypted with the same key
+	// byte differ only as much as the plaintext does, which (for text) is less than random bytes.
+	var cands []XORCandidate
+	for ks := o.MinKeySize; ks <= o.MaxKeySize; ks++ {
+		cands = append(cands, XORCandidate{Key: make([]byte, ks), Dist: blockDist(enc, ks, o.Blocks)})
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].Dist < cands[j].Dist })
+	if len(cands) > o.KeySizes {
+		cands = cands[:o.KeySizes]
+	}
+
+	seen := make(map[string]struct{})
+	uniq := cands[:0]
+	for _, c := range cands {
+		ks := len(c.Key)
+		c.Key = recoverXORKey(enc, ks, o.Scorer)
+		// Columns for multiples of the actual key size are shorter, so a few of their bytes
+		// may be recovered incorrectly. Recover the key again using the shorter size if most
+		// of the key repeats with it so that the overfitted key isn't ranked above it.
+		if n := approxPeriod(c.Key); n < len(c.Key) {
+			c.Key = recoverXORKey(enc, n, o.Scorer)
+		}
+		c.Key = c.Key[:minPeriod(c.Key)]
+		if len(c.Key) != ks {
+			c.Dist = blockDist(enc, len(c.Key), o.Blocks)
+		}
+		if _, ok := seen[string(c.Key)]; ok {
+			continue
+		}
+		seen[string(c.Key)] = struct{}{}
+		c.Plain = XOR(enc, c.Key)
+		c.Score = o.Scorer.Score(c.Plain)
+		uniq = append(uniq, c)
+	}
+	cands = uniq
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].Score > cands[j].Score })
+	return cands, nil
+}
+
+// recoverXORKey recovers a key of size ks that was used to encrypt enc with repeating-key XOR.
+// Each byte of the key was used for single-byte XOR against a column of the ciphertext.
+func recoverXORKey(enc []byte, ks int, sc Scorer) []byte {
+	key := make([]byte, ks)
+	for i, col := range Columns(enc, ks) {
+		key[i] = SingleByteXORScorer(col, sc)
+	}
+	return key
+}
+
+// blockDist returns the average normalized Hamming distance between all pairs of
+// the first nb blocks of size bs in b.
+func blockDist(b []byte, bs, nb int) float64 {
+	var sum float64
+	var pairs int
+	for i := 0; i < nb; i++ {
+		for j := i + 1; j < nb; j++ {
+			sum += float64(Hamming(b[i*bs:(i+1)*bs], b[j*bs:(j+1)*bs])) / float64(bs)
+			pairs++
+		}
+	}
+	return sum / float64(pairs)
+}
+
+// minPeriod returns the length of the shortest prefix of b that can be repeated to produce b.
+func minPeriod(b []byte) int {
+	for n := 1; n < len(b); n++ {
+		if len(b)%n == 0 && bytes.Equal(b[n:], b[:len(b)-n]) {
+			return n
+		}
+	}
+	return len(b)
+}
+
+// approxPeriod returns the smallest n dividing len(b) such that at least three quarters of
+// b's bytes match the most common byte at the same position modulo n.
+func approxPeriod(b []byte) int {
+	for n := 1; n < len(b); n++ {
+		if len(b)%n != 0 {
+			continue
+		}
+		matches := 0
+		for _, col := range Columns(b, n) {
+			counts := make(map[byte]int)
+			max := 0
+			for _, v := range col {
+				if counts[v]++; counts[v] > max {
+					max = counts[v]
+				}
+			}
+			matches += max
+		}
+		if 4*matches >= 3*len(b) {
+			return n
+		}
+	}
+	return len(b)
+}
+
+// Columns splits b into n columns, where column i contains every nth byte of b
+// starting at index i. This is useful for attacking repeating-key ciphers.
+func Columns(b []byte, n int) [][]byte {
+	cols := make([][]byte, n)
+	for i := range cols {
+		cols[i] = make([]byte, 0, len(b)/n+1)
+	}
+	for i, v := range b {
+		cols[i%n] = append(cols[i%n], v)
+	}
+	return cols
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package common
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// testEnglish is English text used by tests that need to score plaintext.
+const testEnglish = `It was the best of times, it was the worst of times, it was the age of wisdom, ` +
+	`it was the age of foolishness, it was the epoch of belief, it was the epoch of incredulity, ` +
+	`it was the season of Light, it was the season of Darkness, it was the spring of hope, ` +
+	`it was the winter of despair, we had everything before us, we had nothing before us, ` +
+	`we were all going direct to Heaven, we were all going direct the other way - in short, ` +
+	`the period was so far like the present period, that some of its noisiest authorities ` +
+	`insisted on its being received, for good or for evil, in the superlative degree of ` +
+	`comparison only. There were a king with a large jaw and a queen with a plain face, on the ` +
+	`throne of England; there were a king with a large jaw and a queen with a fair face, on the ` +
+	`throne of France. In both countries it was clearer than crystal to the lords of the State ` +
+	`preserves of loaves and fishes, that things in general were settled for ever.`
+
+func TestBreakRepeatingXOR(t *testing.T) {
+	for _, tc := range []struct {
+		key   string
+		first bool // key should be the top-ranked candidate
+	}{
+		{"ICE", true},
+		{"YELLOW SUBMARINE", true},
+		{"Terminator", true},
+	} {
+		key := tc.key
+		enc := XOR([]byte(testEnglish), []byte(key))
+		cands, err := BreakRepeatingXOR(enc, &XOROptions{Blocks: 8})
+		if err != nil {
+			t.Errorf("BreakRepeatingXOR with key %q failed: %v", key, err)
+			continue
+		}
+		if len(cands) == 0 || len(cands) > 5 {
+			t.Errorf("BreakRepeatingXOR with key %q returned %v candidates; want 1-5", key, len(cands))
+			continue
+		}
+		found := false
+		for i, c := range cands {
+			if string(c.Key) == key && string(c.Plain) == testEnglish {
+				found = true
+				if tc.first && i != 0 {
+					t.Errorf("BreakRepeatingXOR with key %q ranked correct key at %d", key, i)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("BreakRepeatingXOR with key %q didn't return key; top is %q", key, cands[0].Key)
+		}
+		for i := 1; i < len(cands); i++ {
+			if cands[i].Score > cands[i-1].Score {
+				t.Errorf("BreakRepeatingXOR with key %q returned misordered candidates %d and %d", key, i-1, i)
+			}
+		}
+	}
+
+	if _, err := BreakRepeatingXOR(make([]byte, 7), nil); err == nil {
+		t.Error("BreakRepeatingXOR unexpectedly accepted short input")
+	}
+}
+
+func TestColumns(t *testing.T) {
+	got := Columns([]byte("abcdefg"), 3)
+	want := [][]byte{[]byte("adg"), []byte("be"), []byte("cf")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Columns(%q, 3) = %q; want %q", "abcdefg", got, want)
+	}
+	if got := bytes.Join(Columns(nil, 2), nil); len(got) != 0 {
+		t.Errorf("Columns(nil, 2) contains %q", got)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/derat/cryptopals
 
-go 1.14
+go 1.16
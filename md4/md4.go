@@ -121,8 +121,44 @@ func (d0 *digest) Sum(in []byte) []byte {
 	return in
 }
 
+// Sum returns the MD4 checksum of the data.
+func Sum(data []byte) [Size]byte {
+	var d digest
+	d.Reset()
+	d.Write(data)
+	var sum [Size]byte
+	d.Sum(sum[:0])
+	return sum
+}
+
 // SetState sets h to the supplied state to enable MAC length-extension attacks.
 // h must have been returned by an earlier call to this package's New() function.
+// The number of bytes written to h is unchanged.
+//
+// Deprecated: Use NewFromState, which also sets the processed length.
 func SetState(h hash.Hash, state [4]uint32) {
-	copy(h.(*digest).s[:], state[:])
+	h.(*digest).s = state
+}
+
+// NewFromState returns a new hash.Hash that resumes a checksum computation from the
+// chaining variables in state after processedLen bytes have been hashed. processedLen
+// must be a multiple of BlockSize. This is useful for length-extension attacks, where
+// state is read from a MAC and processedLen is the length of the padded original message.
+func NewFromState(state [4]uint32, processedLen uint64) hash.Hash {
+	if processedLen%BlockSize != 0 {
+		panic("processed length isn't a multiple of block size")
+	}
+	d := new(digest)
+	d.s = state
+	d.len = processedLen
+	return d
+}
+
+// State returns the chaining variables of h, which must have been returned by this
+// package's New or NewFromState function, along with the number of bytes that they
+// reflect. Bytes that have been written to h but not yet processed (i.e. the final
+// len%BlockSize bytes) are not included.
+func State(h hash.Hash) (state [4]uint32, processedLen uint64) {
+	d := h.(*digest)
+	return d.s, d.len - uint64(d.nx)
 }
@@ -0,0 +1,67 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package md4_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/derat/cryptopals/common"
+	"github.com/derat/cryptopals/md4"
+)
+
+func TestCompress(t *testing.T) {
+	iv, _ := md4.State(md4.New())
+	for _, tc := range []struct {
+		msg, sum string
+	}{
+		// Vectors from RFC 1320.
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890",
+			"e33b4ddc9c38f2199c3e7b164fcc0536"},
+	} {
+		state := iv
+		p := append([]byte(tc.msg), common.MDPadding(len(tc.msg), binary.LittleEndian)...)
+		for i := 0; i < len(p); i += md4.BlockSize {
+			state = md4.Compress(state, p[i:i+md4.BlockSize])
+		}
+		var b bytes.Buffer
+		binary.Write(&b, binary.LittleEndian, state)
+		if got := hex.EncodeToString(b.Bytes()); got != tc.sum {
+			t.Errorf("Compressing %q produced %v; want %v", tc.msg, got, tc.sum)
+		}
+		if sum := md4.Sum([]byte(tc.msg)); hex.EncodeToString(sum[:]) != tc.sum {
+			t.Errorf("md4.Sum(%q) = %x; want %v", tc.msg, sum, tc.sum)
+		}
+	}
+
+	block := append([]byte("abc"), common.MDPadding(3, binary.LittleEndian)...)
+	if got, want := md4.CompressRounds(iv, block, md4.Rounds), md4.Compress(iv, block); got != want {
+		t.Errorf("md4.CompressRounds(..., %v) = %x; want %x", md4.Rounds, got, want)
+	}
+	// With no rounds, the state is just added to itself.
+	var want [4]uint32
+	for i, v := range iv {
+		want[i] = 2 * v
+	}
+	if got := md4.CompressRounds(iv, block, 0); got != want {
+		t.Errorf("md4.CompressRounds(..., 0) = %x; want %x", got, want)
+	}
+	// The first step only updates A.
+	if got := md4.CompressRounds(iv, block, 1); got[0] == want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Errorf("md4.CompressRounds(..., 1) = %x; want only first word to differ from %x", got, want)
+	}
+	prev := want
+	for r := 1; r < md4.Rounds; r++ {
+		got := md4.CompressRounds(iv, block, r)
+		if got == prev {
+			t.Errorf("md4.CompressRounds(..., %v) didn't change the result", r)
+		}
+		prev = got
+	}
+}
@@ -15,75 +15,103 @@ var shift3 = []uint{3, 9, 11, 15}
 var xIndex2 = []uint{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}
 var xIndex3 = []uint{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}
 
+// Rounds is the number of steps performed by MD4's compression function. Note that
+// MD4's 48 steps are conventionally described as three rounds of 16 steps each, but
+// CompressRounds counts individual steps to match the sha1 package.
+const Rounds = 48
+
+// Compress returns the result of running MD4's compression function on a single
+// BlockSize-byte block using the chaining variables in state.
+func Compress(state [4]uint32, block []byte) [4]uint32 {
+	return CompressRounds(state, block, Rounds)
+}
+
+// CompressRounds is like Compress but only performs the first rounds steps
+// (in the range [0, Rounds]) before adding the original state to the result.
+func CompressRounds(state [4]uint32, block []byte, rounds int) [4]uint32 {
+	if len(block) != _Chunk {
+		panic("block must be BlockSize bytes")
+	}
+	if rounds < 0 || rounds > Rounds {
+		panic("invalid round count")
+	}
+	compress(&state, block, rounds)
+	return state
+}
+
 func _Block(dig *digest, p []byte) int {
-	a := dig.s[0]
-	b := dig.s[1]
-	c := dig.s[2]
-	d := dig.s[3]
 	n := 0
-	var X [16]uint32
 	for len(p) >= _Chunk {
-		aa, bb, cc, dd := a, b, c, d
-
-		j := 0
-		for i := 0; i < 16; i++ {
-			X[i] = uint32(p[j]) | uint32(p[j+1])<<8 | uint32(p[j+2])<<16 | uint32(p[j+3])<<24
-			j += 4
-		}
-
-		// If this needs to be made faster in the future,
-		// the usual trick is to unroll each of these
-		// loops by a factor of 4; that lets you replace
-		// the shift[] lookups with constants and,
-		// with suitable variable renaming in each
-		// unrolled body, delete the a, b, c, d = d, a, b, c
-		// (or you can let the optimizer do the renaming).
-		//
-		// The index variables are uint so that % by a power
-		// of two can be optimized easily by a compiler.
-
-		// Round 1.
-		for i := uint(0); i < 16; i++ {
-			x := i
-			s := shift1[i%4]
-			f := ((c ^ d) & b) ^ d
-			a += f + X[x]
-			a = a<<s | a>>(32-s)
-			a, b, c, d = d, a, b, c
-		}
-
-		// Round 2.
-		for i := uint(0); i < 16; i++ {
-			x := xIndex2[i]
-			s := shift2[i%4]
-			g := (b & c) | (b & d) | (c & d)
-			a += g + X[x] + 0x5a827999
-			a = a<<s | a>>(32-s)
-			a, b, c, d = d, a, b, c
-		}
-
-		// Round 3.
-		for i := uint(0); i < 16; i++ {
-			x := xIndex3[i]
-			s := shift3[i%4]
-			h := b ^ c ^ d
-			a += h + X[x] + 0x6ed9eba1
-			a = a<<s | a>>(32-s)
-			a, b, c, d = d, a, b, c
-		}
-
-		a += aa
-		b += bb
-		c += cc
-		d += dd
-
+		compress(&dig.s, p[:_Chunk], Rounds)
 		p = p[_Chunk:]
 		n += _Chunk
 	}
-
-	dig.s[0] = a
-	dig.s[1] = b
-	dig.s[2] = c
-	dig.s[3] = d
 	return n
 }
+
+// compress performs the first rounds steps of the compression function on
+// the single block p and adds the result to st.
+func compress(st *[4]uint32, p []byte, rounds int) {
+	a, b, c, d := st[0], st[1], st[2], st[3]
+	aa, bb, cc, dd := a, b, c, d
+
+	var X [16]uint32
+	j := 0
+	for i := 0; i < 16; i++ {
+		X[i] = uint32(p[j]) | uint32(p[j+1])<<8 | uint32(p[j+2])<<16 | uint32(p[j+3])<<24
+		j += 4
+	}
+
+	// If this needs to be made faster in the future,
+	// the usual trick is to unroll each of these
+	// loops by a factor of 4; that lets you replace
+	// the shift[] lookups with constants and,
+	// with suitable variable renaming in each
+	// unrolled body, delete the a, b, c, d = d, a, b, c
+	// (or you can let the optimizer do the renaming).
+	//
+	// The index variables are uint so that % by a power
+	// of two can be optimized easily by a compiler.
+	steps := 0
+
+	// Round 1.
+	for i := uint(0); i < 16 && steps < rounds; i, steps = i+1, steps+1 {
+		x := i
+		s := shift1[i%4]
+		f := ((c ^ d) & b) ^ d
+		a += f + X[x]
+		a = a<<s | a>>(32-s)
+		a, b, c, d = d, a, b, c
+	}
+
+	// Round 2.
+	for i := uint(0); i < 16 && steps < rounds; i, steps = i+1, steps+1 {
+		x := xIndex2[i]
+		s := shift2[i%4]
+		g := (b & c) | (b & d) | (c & d)
+		a += g + X[x] + 0x5a827999
+		a = a<<s | a>>(32-s)
+		a, b, c, d = d, a, b, c
+	}
+
+	// Round 3.
+	for i := uint(0); i < 16 && steps < rounds; i, steps = i+1, steps+1 {
+		x := xIndex3[i]
+		s := shift3[i%4]
+		h := b ^ c ^ d
+		a += h + X[x] + 0x6ed9eba1
+		a = a<<s | a>>(32-s)
+		a, b, c, d = d, a, b, c
+	}
+
+	// a, b, c, and d are rotated after each step, so keep rotating them until
+	// they line up with st again if the final round was cut short.
+	for ; steps%4 != 0; steps++ {
+		a, b, c, d = d, a, b, c
+	}
+
+	st[0] = a + aa
+	st[1] = b + bb
+	st[2] = c + cc
+	st[3] = d + dd
+}
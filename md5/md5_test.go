@@ -0,0 +1,45 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package md5
+
+import (
+	"bytes"
+	stdmd5 "crypto/md5"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	for _, tc := range []struct {
+		msg, sum string
+	}{
+		// Vectors from RFC 1321.
+		{"", "d41d8cd98f00b204e9800998ecf8427e"},
+		{"a", "0cc175b9c0f1b6a831c399e269772661"},
+		{"abc", "900150983cd24fb0d6963f7d28e17f72"},
+		{"message digest", "f96b697d7cb7938d525a2f31aaf161d0"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890",
+			"57edf4a22be3c955ac49da2e2107b67a"},
+	} {
+		h := New()
+		// Write the message in two pieces to exercise buffering.
+		h.Write([]byte(tc.msg[:len(tc.msg)/2]))
+		h.Write([]byte(tc.msg[len(tc.msg)/2:]))
+		if got := hex.EncodeToString(h.Sum(nil)); got != tc.sum {
+			t.Errorf("Hashing %q produced %v; want %v", tc.msg, got, tc.sum)
+		}
+		if sum := Sum([]byte(tc.msg)); hex.EncodeToString(sum[:]) != tc.sum {
+			t.Errorf("Sum(%q) = %x; want %v", tc.msg, sum, tc.sum)
+		}
+	}
+
+	// Also compare against the standard library for messages spanning block boundaries.
+	data := bytes.Repeat([]byte("0123456789"), 20)
+	for n := 0; n <= len(data); n++ {
+		if got, want := Sum(data[:n]), stdmd5.Sum(data[:n]); got != want {
+			t.Errorf("Sum of %v bytes is %x; want %x", n, got, want)
+		}
+	}
+}
@@ -267,6 +267,32 @@ func Sum(data []byte) [Size]byte {
 
 // SetState sets h to the supplied state to enable MAC length-extension attacks.
 // h must have been returned by an earlier call to this package's New() function.
+// The number of bytes written to h is unchanged.
+//
+// Deprecated: Use NewFromState, which also sets the processed length.
 func SetState(h hash.Hash, state [5]uint32) {
-	copy(h.(*digest).h[:], state[:])
+	h.(*digest).h = state
+}
+
+// NewFromState returns a new hash.Hash that resumes a checksum computation from the
+// chaining variables in state after processedLen bytes have been hashed. processedLen
+// must be a multiple of BlockSize. This is useful for length-extension attacks, where
+// state is read from a MAC and processedLen is the length of the padded original message.
+func NewFromState(state [5]uint32, processedLen uint64) hash.Hash {
+	if processedLen%BlockSize != 0 {
+		panic("processed length isn't a multiple of block size")
+	}
+	d := new(digest)
+	d.h = state
+	d.len = processedLen
+	return d
+}
+
+// State returns the chaining variables of h, which must have been returned by this
+// package's New or NewFromState function, along with the number of bytes that they
+// reflect. Bytes that have been written to h but not yet processed (i.e. the final
+// len%BlockSize bytes) are not included.
+func State(h hash.Hash) (state [5]uint32, processedLen uint64) {
+	d := h.(*digest)
+	return d.h, d.len - uint64(d.nx)
 }
@@ -0,0 +1,59 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sha1_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/derat/cryptopals/common"
+	"github.com/derat/cryptopals/sha1"
+)
+
+func TestCompress(t *testing.T) {
+	iv, _ := sha1.State(sha1.New())
+	for _, tc := range []struct {
+		msg, sum string
+	}{
+		{"", "da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+		{"abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890",
+			"50abf5706a150990a08b2c5ea40fa0e585554732"},
+	} {
+		state := iv
+		p := append([]byte(tc.msg), common.MDPadding(len(tc.msg), binary.BigEndian)...)
+		for i := 0; i < len(p); i += sha1.BlockSize {
+			state = sha1.Compress(state, p[i:i+sha1.BlockSize])
+		}
+		var b bytes.Buffer
+		binary.Write(&b, binary.BigEndian, state)
+		if got := hex.EncodeToString(b.Bytes()); got != tc.sum {
+			t.Errorf("Compressing %q produced %v; want %v", tc.msg, got, tc.sum)
+		}
+	}
+
+	block := append([]byte("abc"), common.MDPadding(3, binary.BigEndian)...)
+	if got, want := sha1.CompressRounds(iv, block, sha1.Rounds), sha1.Compress(iv, block); got != want {
+		t.Errorf("sha1.CompressRounds(..., %v) = %x; want %x", sha1.Rounds, got, want)
+	}
+	// With no rounds, the state is just added to itself.
+	var want [5]uint32
+	for i, v := range iv {
+		want[i] = 2 * v
+	}
+	if got := sha1.CompressRounds(iv, block, 0); got != want {
+		t.Errorf("sha1.CompressRounds(..., 0) = %x; want %x", got, want)
+	}
+	prev := want
+	for r := 1; r < sha1.Rounds; r++ {
+		got := sha1.CompressRounds(iv, block, r)
+		if got == prev {
+			t.Errorf("sha1.CompressRounds(..., %v) didn't change the result", r)
+		}
+		prev = got
+	}
+}
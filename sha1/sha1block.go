@@ -15,69 +15,93 @@ const (
 	_K3 = 0xCA62C1D6
 )
 
+// Rounds is the number of rounds (or steps) performed by SHA-1's compression function.
+const Rounds = 80
+
+// Compress returns the result of running SHA-1's compression function on a single
+// BlockSize-byte block using the chaining variables in state.
+func Compress(state [5]uint32, block []byte) [5]uint32 {
+	return CompressRounds(state, block, Rounds)
+}
+
+// CompressRounds is like Compress but only performs the first rounds rounds
+// (in the range [0, Rounds]) before adding the original state to the result.
+func CompressRounds(state [5]uint32, block []byte, rounds int) [5]uint32 {
+	if len(block) != chunk {
+		panic("block must be BlockSize bytes")
+	}
+	if rounds < 0 || rounds > Rounds {
+		panic("invalid round count")
+	}
+	compress(&state, block, rounds)
+	return state
+}
+
 // blockGeneric is a portable, pure Go version of the SHA-1 block step.
 // It's used by sha1block_generic.go and tests.
 func blockGeneric(dig *digest, p []byte) {
-	var w [16]uint32
-
-	h0, h1, h2, h3, h4 := dig.h[0], dig.h[1], dig.h[2], dig.h[3], dig.h[4]
 	for len(p) >= chunk {
-		// Can interlace the computation of w with the
-		// rounds below if needed for speed.
-		for i := 0; i < 16; i++ {
-			j := i * 4
-			w[i] = uint32(p[j])<<24 | uint32(p[j+1])<<16 | uint32(p[j+2])<<8 | uint32(p[j+3])
-		}
+		compress(&dig.h, p[:chunk], Rounds)
+		p = p[chunk:]
+	}
+}
 
-		a, b, c, d, e := h0, h1, h2, h3, h4
+// compress performs the first rounds rounds of the compression function on
+// the single block p and adds the result to h.
+func compress(h *[5]uint32, p []byte, rounds int) {
+	var w [16]uint32
 
-		// Each of the four 20-iteration rounds
-		// differs only in the computation of f and
-		// the choice of K (_K0, _K1, etc).
-		i := 0
-		for ; i < 16; i++ {
-			f := b&c | (^b)&d
-			t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K0
-			a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
-		}
-		for ; i < 20; i++ {
-			tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
-			w[i&0xf] = tmp<<1 | tmp>>(32-1)
+	// Can interlace the computation of w with the
+	// rounds below if needed for speed.
+	for i := 0; i < 16; i++ {
+		j := i * 4
+		w[i] = uint32(p[j])<<24 | uint32(p[j+1])<<16 | uint32(p[j+2])<<8 | uint32(p[j+3])
+	}
 
-			f := b&c | (^b)&d
-			t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K0
-			a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
-		}
-		for ; i < 40; i++ {
-			tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
-			w[i&0xf] = tmp<<1 | tmp>>(32-1)
-			f := b ^ c ^ d
-			t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K1
-			a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
-		}
-		for ; i < 60; i++ {
-			tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
-			w[i&0xf] = tmp<<1 | tmp>>(32-1)
-			f := ((b | c) & d) | (b & c)
-			t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K2
-			a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
-		}
-		for ; i < 80; i++ {
-			tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
-			w[i&0xf] = tmp<<1 | tmp>>(32-1)
-			f := b ^ c ^ d
-			t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K3
-			a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
-		}
+	a, b, c, d, e := h[0], h[1], h[2], h[3], h[4]
 
-		h0 += a
-		h1 += b
-		h2 += c
-		h3 += d
-		h4 += e
+	// Each of the four 20-iteration rounds
+	// differs only in the computation of f and
+	// the choice of K (_K0, _K1, etc).
+	i := 0
+	for ; i < 16 && i < rounds; i++ {
+		f := b&c | (^b)&d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K0
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < 20 && i < rounds; i++ {
+		tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
+		w[i&0xf] = tmp<<1 | tmp>>(32-1)
 
-		p = p[chunk:]
+		f := b&c | (^b)&d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K0
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < 40 && i < rounds; i++ {
+		tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
+		w[i&0xf] = tmp<<1 | tmp>>(32-1)
+		f := b ^ c ^ d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K1
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < 60 && i < rounds; i++ {
+		tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
+		w[i&0xf] = tmp<<1 | tmp>>(32-1)
+		f := ((b | c) & d) | (b & c)
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K2
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
+	}
+	for ; i < 80 && i < rounds; i++ {
+		tmp := w[(i-3)&0xf] ^ w[(i-8)&0xf] ^ w[(i-14)&0xf] ^ w[(i)&0xf]
+		w[i&0xf] = tmp<<1 | tmp>>(32-1)
+		f := b ^ c ^ d
+		t := bits.RotateLeft32(a, 5) + f + e + w[i&0xf] + _K3
+		a, b, c, d, e = t, a, bits.RotateLeft32(b, 30), c, d
 	}
 
-	dig.h[0], dig.h[1], dig.h[2], dig.h[3], dig.h[4] = h0, h1, h2, h3, h4
+	h[0] += a
+	h[1] += b
+	h[2] += c
+	h[3] += d
+	h[4] += e
 }
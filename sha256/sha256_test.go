@@ -0,0 +1,43 @@
+// Copyright 2020 Daniel Erat. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sha256
+
+import (
+	"bytes"
+	stdsha256 "crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	for _, tc := range []struct {
+		msg, sum string
+	}{
+		// Vectors from FIPS 180-2.
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq",
+			"248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"},
+	} {
+		h := New()
+		// Write the message in two pieces to exercise buffering.
+		h.Write([]byte(tc.msg[:len(tc.msg)/2]))
+		h.Write([]byte(tc.msg[len(tc.msg)/2:]))
+		if got := hex.EncodeToString(h.Sum(nil)); got != tc.sum {
+			t.Errorf("Hashing %q produced %v; want %v", tc.msg, got, tc.sum)
+		}
+		if sum := Sum256([]byte(tc.msg)); hex.EncodeToString(sum[:]) != tc.sum {
+			t.Errorf("Sum256(%q) = %x; want %v", tc.msg, sum, tc.sum)
+		}
+	}
+
+	// Also compare against the standard library for messages spanning block boundaries.
+	data := bytes.Repeat([]byte("0123456789"), 20)
+	for n := 0; n <= len(data); n++ {
+		if got, want := Sum256(data[:n]), stdsha256.Sum256(data[:n]); got != want {
+			t.Errorf("Sum256 of %v bytes is %x; want %x", n, got, want)
+		}
+	}
+}